@@ -1,4 +1,5 @@
 #!/bin/bash
 
 go build  -tags=fetcherExec -o fetcher fetcher.go && \
-go build  -tags=extractorExec -o extractor extractor.go 
+go build  -tags=extractorExec -o extractor extractor.go && \
+go build  -tags=recrawlerExec -o recrawler recrawler.go
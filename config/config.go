@@ -0,0 +1,511 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/content"
+	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/parse"
+	"github.com/fmpwizard/owlcrawler/recrawl"
+	"github.com/fmpwizard/owlcrawler/retry"
+)
+
+//DefaultFile is where we look for the config file, relative to $HOME
+const DefaultFile = ".owlcrawler.json"
+
+//Config holds the settings shared by the fetcher, extractor and webapp.
+//Values come from the config file, then environment variables, then flags
+type Config struct {
+	//Store is where we keep pages: couchdb, cloudant or memory
+	Store    string              `json:"store"`
+	CouchDB  couchdb.Credentials `json:"couchdb"`
+	Cloudant couchdb.Credentials `json:"cloudant"`
+	Gnatsd   Gnatsd              `json:"gnatsd"`
+	Fetcher  Fetcher             `json:"fetcher"`
+	Recrawl  Recrawl             `json:"recrawl"`
+	Frontier Frontier            `json:"frontier"`
+	Seen     Seen                `json:"seen"`
+	Feeds    Feeds               `json:"feeds"`
+	//ShutdownTimeout is how long workers get to finish what they are doing once asked to stop
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	//Normalize sets the optional steps of url normalization
+	Normalize Normalize `json:"normalize"`
+}
+
+//Gnatsd tells us how to connect to gnatsd
+type Gnatsd struct {
+	URL string
+}
+
+//Fetcher holds settings only the fetcher uses
+type Fetcher struct {
+	//CrawlDelay is the default minimum time between two requests to the same host
+	CrawlDelay Duration `json:"crawl_delay"`
+	//RecrawlAfter is how old a page has to be before a recrawl request fetches it again
+	RecrawlAfter Duration `json:"recrawl_after"`
+	//IndexErrors sends pages that came back with a non 2xx status to the extractor too
+	IndexErrors bool `json:"index_errors"`
+	//CanonicalRedirects stores a redirected page under the url it redirected to,
+	//leaving a document that points to it under the url we asked for
+	CanonicalRedirects bool `json:"canonical_redirects"`
+	//MaxAttempts is how many times we try to fetch a url before giving up on it,
+	//waiting RetryBase before the first retry and doubling it each time up to RetryMax
+	MaxAttempts int      `json:"max_attempts"`
+	RetryBase   Duration `json:"retry_base"`
+	RetryMax    Duration `json:"retry_max"`
+	//MaxBodySize is how many bytes of a page we read, longer pages are truncated
+	MaxBodySize int64 `json:"max_body_size"`
+	//HeadFirst sends a HEAD request before fetching a new url, so we can skip
+	//content types we don't want without starting the download
+	HeadFirst bool           `json:"head_first"`
+	Content   content.Filter `json:"content"`
+	//Workers is how many urls one fetcher fetches at the same time,
+	//PerHost how many of them can be from the same host
+	Workers int `json:"workers"`
+	PerHost int `json:"per_host"`
+	//Timeout is how long we give a request, reading the body included
+	Timeout Duration `json:"timeout"`
+	//Buffer is how many urls one fetcher takes from the queue ahead of its workers,
+	//to pick the highest priority one whose host is free
+	Buffer int `json:"buffer"`
+}
+
+//RetryPolicy is the retry policy described by the config
+func (f Fetcher) RetryPolicy() retry.Policy {
+	return retry.Policy{
+		MaxAttempts: f.MaxAttempts,
+		Base:        f.RetryBase.Duration,
+		Max:         f.RetryMax.Duration,
+	}
+}
+
+//Frontier holds the settings of the queue of urls to fetch
+type Frontier struct {
+	//AckTimeout is how long a fetcher has to be done with a url before we send it to another one
+	AckTimeout Duration `json:"ack_timeout"`
+	//RedeliverEvery is how often fetchers look for urls nobody was done with in time
+	RedeliverEvery Duration `json:"redeliver_every"`
+	//MaxDeliveries is how many times we send a url before giving up on it
+	MaxDeliveries int `json:"max_deliveries"`
+}
+
+//Seen holds the settings of the set of urls the extractors already queued
+type Seen struct {
+	//File is where the extractor keeps the set between runs, empty to keep it only in memory
+	File     string `json:"file"`
+	Capacity int    `json:"capacity"`
+	//ErrorRate is how often the set wrongly says it saw a url, those links are not queued
+	ErrorRate float64 `json:"error_rate"`
+	//SaveEvery is how often we write the set to File
+	SaveEvery Duration `json:"save_every"`
+}
+
+//Feeds holds the settings of the RSS and Atom feeds we poll for new pages
+type Feeds struct {
+	//Interval is how often we read a feed again
+	Interval Duration `json:"interval"`
+}
+
+//Normalize holds the optional steps of url normalization. Document ids come from
+//normalized urls, so every process has to use the same settings
+type Normalize struct {
+	//SortQuery sorts query parameters by name
+	SortQuery bool `json:"sort_query"`
+	//StripTracking removes the TrackingParams, a trailing * matches any parameter with that prefix
+	StripTracking  bool     `json:"strip_tracking"`
+	TrackingParams []string `json:"tracking_params"`
+}
+
+//Options are the parse.NormalizeOptions described by the config
+func (n Normalize) Options() parse.NormalizeOptions {
+	return parse.NormalizeOptions{
+		SortQuery:      n.SortQuery,
+		StripTracking:  n.StripTracking,
+		TrackingParams: n.TrackingParams,
+	}
+}
+
+//Recrawl holds the settings of the recrawler
+type Recrawl struct {
+	//Every is how often we look for pages that are due for a revisit
+	Every Duration `json:"every"`
+	//SiteBudget is how many pages of one site we send to the fetchers each time
+	SiteBudget int `json:"site_budget"`
+	//Initial, MinInterval and MaxInterval bound how often we revisit a page
+	Initial     Duration `json:"initial_interval"`
+	MinInterval Duration `json:"min_interval"`
+	MaxInterval Duration `json:"max_interval"`
+}
+
+//Policy is the revisit policy described by the config
+func (r Recrawl) Policy() recrawl.Policy {
+	return recrawl.Policy{
+		Initial: r.Initial.Duration,
+		Min:     r.MinInterval.Duration,
+		Max:     r.MaxInterval.Duration,
+	}
+}
+
+//Duration is a time.Duration written as "5s" in the config file
+type Duration struct {
+	time.Duration
+}
+
+//UnmarshalJSON reads durations like "1m30s"
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+//MarshalJSON writes durations like "1m30s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+//Default is the config we start from before reading the file
+func Default() *Config {
+	return &Config{
+		Store:           "couchdb",
+		Gnatsd:          Gnatsd{URL: "nats://127.0.0.1:4222"},
+		ShutdownTimeout: Duration{30 * time.Second},
+		Fetcher: Fetcher{
+			CrawlDelay:         Duration{5 * time.Second},
+			RecrawlAfter:       Duration{24 * time.Hour},
+			CanonicalRedirects: true,
+			MaxAttempts:        retry.DefaultPolicy.MaxAttempts,
+			RetryBase:          Duration{retry.DefaultPolicy.Base},
+			RetryMax:           Duration{retry.DefaultPolicy.Max},
+			MaxBodySize:        5 << 20,
+			Content:            content.DefaultFilter,
+			Workers:            8,
+			PerHost:            2,
+			Timeout:            Duration{time.Minute},
+			Buffer:             100,
+		},
+		Frontier: Frontier{
+			AckTimeout:     Duration{10 * time.Minute},
+			RedeliverEvery: Duration{30 * time.Second},
+			MaxDeliveries:  10,
+		},
+		Seen: Seen{
+			File:      ".owlcrawler-seen",
+			Capacity:  1000000,
+			ErrorRate: 0.001,
+			SaveEvery: Duration{5 * time.Minute},
+		},
+		Feeds: Feeds{
+			Interval: Duration{30 * time.Minute},
+		},
+		Normalize: Normalize{
+			SortQuery:      parse.DefaultNormalizeOptions.SortQuery,
+			StripTracking:  parse.DefaultNormalizeOptions.StripTracking,
+			TrackingParams: parse.DefaultNormalizeOptions.TrackingParams,
+		},
+		Recrawl: Recrawl{
+			Every:       Duration{5 * time.Minute},
+			SiteBudget:  100,
+			Initial:     Duration{recrawl.DefaultPolicy.Initial},
+			MinInterval: Duration{recrawl.DefaultPolicy.Min},
+			MaxInterval: Duration{recrawl.DefaultPolicy.Max},
+		},
+	}
+}
+
+//setting is a value that can be overridden by a flag and an environment variable
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setBool(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value %s, use true or false", value)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("Invalid number %s, got: %v", value, err)
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+var settings = []setting{
+	{"store", "OWLCRAWLER_STORE", "where we keep pages: couchdb, cloudant or memory",
+		setString(func(c *Config) *string { return &c.Store })},
+	{"couchdb-url", "OWLCRAWLER_COUCHDB_URL", "CouchDB database url",
+		setString(func(c *Config) *string { return &c.CouchDB.URL })},
+	{"couchdb-user", "OWLCRAWLER_COUCHDB_USER", "CouchDB user",
+		setString(func(c *Config) *string { return &c.CouchDB.User })},
+	{"couchdb-password", "OWLCRAWLER_COUCHDB_PASSWORD", "CouchDB password",
+		setString(func(c *Config) *string { return &c.CouchDB.Password })},
+	{"cloudant-url", "OWLCRAWLER_CLOUDANT_URL", "Cloudant database url",
+		setString(func(c *Config) *string { return &c.Cloudant.URL })},
+	{"cloudant-user", "OWLCRAWLER_CLOUDANT_USER", "Cloudant user",
+		setString(func(c *Config) *string { return &c.Cloudant.User })},
+	{"cloudant-password", "OWLCRAWLER_CLOUDANT_PASSWORD", "Cloudant password",
+		setString(func(c *Config) *string { return &c.Cloudant.Password })},
+	{"gnatsd-url", "OWLCRAWLER_GNATSD_URL", "gnatsd url, including user and password",
+		setString(func(c *Config) *string { return &c.Gnatsd.URL })},
+	{"crawl-delay", "OWLCRAWLER_CRAWL_DELAY", "minimum time between two requests to the same host",
+		func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Invalid crawl delay %s, got: %v", value, err)
+			}
+			c.Fetcher.CrawlDelay.Duration = d
+			return nil
+		}},
+	{"recrawl-after", "OWLCRAWLER_RECRAWL_AFTER", "how old a page has to be before we fetch it again",
+		func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Invalid recrawl after %s, got: %v", value, err)
+			}
+			c.Fetcher.RecrawlAfter.Duration = d
+			return nil
+		}},
+	{"index-errors", "OWLCRAWLER_INDEX_ERRORS", "index pages that came back with a non 2xx status",
+		setBool(func(c *Config) *bool { return &c.Fetcher.IndexErrors })},
+	{"max-body-size", "OWLCRAWLER_MAX_BODY_SIZE", "how many bytes of a page we read, longer pages are truncated",
+		func(c *Config, value string) error {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("Invalid max body size %s, got: %v", value, err)
+			}
+			c.Fetcher.MaxBodySize = size
+			return nil
+		}},
+	{"head-first", "OWLCRAWLER_HEAD_FIRST", "send a HEAD request before fetching new urls",
+		setBool(func(c *Config) *bool { return &c.Fetcher.HeadFirst })},
+	{"shutdown-timeout", "OWLCRAWLER_SHUTDOWN_TIMEOUT", "how long workers get to finish once asked to stop",
+		func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Invalid shutdown timeout %s, got: %v", value, err)
+			}
+			c.ShutdownTimeout.Duration = d
+			return nil
+		}},
+	{"seen-file", "OWLCRAWLER_SEEN_FILE", "where the extractor keeps the urls it already queued",
+		setString(func(c *Config) *string { return &c.Seen.File })},
+	{"feed-interval", "OWLCRAWLER_FEED_INTERVAL", "how often we read a feed again",
+		func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Invalid feed interval %s, got: %v", value, err)
+			}
+			c.Feeds.Interval.Duration = d
+			return nil
+		}},
+	{"workers", "OWLCRAWLER_WORKERS", "how many urls we fetch at the same time",
+		setInt(func(c *Config) *int { return &c.Fetcher.Workers })},
+	{"per-host", "OWLCRAWLER_PER_HOST", "how many urls from the same host we fetch at the same time",
+		setInt(func(c *Config) *int { return &c.Fetcher.PerHost })},
+	{"fetch-buffer", "OWLCRAWLER_FETCH_BUFFER", "how many urls we take from the queue ahead of the workers",
+		setInt(func(c *Config) *int { return &c.Fetcher.Buffer })},
+	{"fetch-timeout", "OWLCRAWLER_FETCH_TIMEOUT", "how long we give a request, reading the body included",
+		func(c *Config, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("Invalid fetch timeout %s, got: %v", value, err)
+			}
+			c.Fetcher.Timeout.Duration = d
+			return nil
+		}},
+	{"canonical-redirects", "OWLCRAWLER_CANONICAL_REDIRECTS", "store redirected pages under the url they redirected to",
+		setBool(func(c *Config) *bool { return &c.Fetcher.CanonicalRedirects })},
+	{"sort-query", "OWLCRAWLER_SORT_QUERY", "sort the query parameters of urls by name",
+		setBool(func(c *Config) *bool { return &c.Normalize.SortQuery })},
+	{"strip-tracking", "OWLCRAWLER_STRIP_TRACKING", "remove tracking parameters like utm_* from urls",
+		setBool(func(c *Config) *bool { return &c.Normalize.StripTracking })},
+}
+
+//Loader reads the config once the command line is parsed
+type Loader struct {
+	fs     *flag.FlagSet
+	path   *string
+	values map[string]*string
+}
+
+//Flags registers the -config flag and one flag per setting on fs
+func Flags(fs *flag.FlagSet) *Loader {
+	l := &Loader{
+		fs:     fs,
+		path:   fs.String("config", "", "config file, defaults to $HOME/"+DefaultFile),
+		values: make(map[string]*string),
+	}
+	for _, s := range settings {
+		l.values[s.flag] = fs.String(s.flag, "", s.usage+", overrides the config file and $"+s.env)
+	}
+	return l
+}
+
+//Load builds the Config from the file, environment and flags, and validates it.
+//It also sets the url normalization options every process uses.
+//Call it after the flag set was parsed
+func (l *Loader) Load() (*Config, error) {
+	c := Default()
+	if err := c.readFile(*l.path); err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(c, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var err error
+	l.fs.Visit(func(f *flag.Flag) {
+		if value, ok := l.values[f.Name]; ok && err == nil {
+			for _, s := range settings {
+				if s.flag == f.Name {
+					err = s.set(c, *value)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	parse.DefaultNormalizeOptions = c.Normalize.Options()
+	return c, nil
+}
+
+//readFile reads the config file. When no path is given and $HOME/.owlcrawler.json
+//doesn't exist, we look for the older per service files
+func (c *Config) readFile(path string) error {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, DefaultFile)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			c.readLegacyFiles(home)
+			return nil
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading config file %s, got: %v", path, err)
+	}
+	if err := json.Unmarshal(content, c); err != nil {
+		return fmt.Errorf("Invalid config file %s, got: %v", path, err)
+	}
+	return nil
+}
+
+//readLegacyFiles reads $HOME/.couchdb.json, .cloudant.json and .gnatsd.json if they exist
+func (c *Config) readLegacyFiles(home string) {
+	legacy := map[string]interface{}{
+		".couchdb.json":  &c.CouchDB,
+		".cloudant.json": &c.Cloudant,
+		".gnatsd.json":   &c.Gnatsd,
+	}
+	for name, v := range legacy {
+		if content, err := ioutil.ReadFile(filepath.Join(home, name)); err == nil {
+			json.Unmarshal(content, v)
+		}
+	}
+}
+
+//Validate checks we have everything we need to start
+func (c *Config) Validate() error {
+	switch c.Store {
+	case "couchdb":
+		if c.CouchDB.URL == "" {
+			return errors.New("Missing CouchDB url, set couchdb.URL in the config file or use -couchdb-url")
+		}
+	case "cloudant":
+		if c.Cloudant.URL == "" {
+			return errors.New("Missing Cloudant url, set cloudant.URL in the config file or use -cloudant-url")
+		}
+	case "memory":
+	default:
+		return fmt.Errorf("Unknown store %s, use couchdb, cloudant or memory", c.Store)
+	}
+	if c.Gnatsd.URL == "" {
+		return errors.New("Missing gnatsd url, set gnatsd.URL in the config file or use -gnatsd-url")
+	}
+	if c.Fetcher.CrawlDelay.Duration <= 0 {
+		return errors.New("Crawl delay has to be positive")
+	}
+	if c.Fetcher.RecrawlAfter.Duration < 0 {
+		return errors.New("Recrawl after can't be negative")
+	}
+	if c.Fetcher.MaxAttempts <= 0 || c.Fetcher.RetryBase.Duration <= 0 || c.Fetcher.RetryBase.Duration > c.Fetcher.RetryMax.Duration {
+		return errors.New("Max attempts and retry base have to be positive, and retry base not more than retry max")
+	}
+	if c.ShutdownTimeout.Duration <= 0 {
+		return errors.New("Shutdown timeout has to be positive")
+	}
+	if c.Fetcher.Workers <= 0 || c.Fetcher.PerHost <= 0 || c.Fetcher.Timeout.Duration <= 0 {
+		return errors.New("Workers, per host and timeout have to be positive")
+	}
+	if c.Fetcher.Buffer <= 0 {
+		return errors.New("Fetch buffer has to be positive")
+	}
+	if c.Fetcher.MaxBodySize <= 0 {
+		return errors.New("Max body size has to be positive")
+	}
+	if len(c.Fetcher.Content.Accept) == 0 {
+		return errors.New("Accept at least one content type")
+	}
+	f := c.Frontier
+	if f.AckTimeout.Duration <= 0 || f.RedeliverEvery.Duration <= 0 || f.MaxDeliveries <= 0 {
+		return errors.New("Frontier ack timeout, redeliver every and max deliveries have to be positive")
+	}
+	if c.Seen.Capacity <= 0 || c.Seen.ErrorRate <= 0 || c.Seen.ErrorRate >= 1 || c.Seen.SaveEvery.Duration <= 0 {
+		return errors.New("Seen capacity and save every have to be positive, and the error rate between 0 and 1")
+	}
+	if c.Feeds.Interval.Duration <= 0 {
+		return errors.New("Feed interval has to be positive")
+	}
+	r := c.Recrawl
+	if r.Every.Duration <= 0 || r.SiteBudget <= 0 {
+		return errors.New("Recrawl every and site budget have to be positive")
+	}
+	if r.MinInterval.Duration <= 0 || r.MinInterval.Duration > r.MaxInterval.Duration {
+		return errors.New("Recrawl min interval has to be positive and not more than the max interval")
+	}
+	return nil
+}
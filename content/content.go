@@ -0,0 +1,71 @@
+package content
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+//Filter decides which responses we keep and which ones we extract text and links from,
+//based on their media type. Types are written as "text/html", or "text/*" for a whole family
+type Filter struct {
+	//Accept are the types we store, everything else is skipped without reading the body
+	Accept []string `json:"accept"`
+	//Extract are the types we send to the extractor, they have to be accepted too
+	Extract []string `json:"extract"`
+}
+
+//DefaultFilter stores text and sends html to the extractor
+var DefaultFilter = Filter{
+	Accept:  []string{"text/*", "application/xhtml+xml", "application/xml"},
+	Extract: []string{"text/html", "application/xhtml+xml"},
+}
+
+//MediaType is the lowercase media type of a Content-Type header, without its parameters
+func MediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	return strings.ToLower(mediaType)
+}
+
+//Sniff guesses the content type from the first bytes of the body, for responses without one
+func Sniff(body []byte) string {
+	return http.DetectContentType(body)
+}
+
+//Accepts tells us if we store responses of the given Content-Type
+func (f Filter) Accepts(contentType string) bool {
+	return matches(f.Accept, MediaType(contentType))
+}
+
+//Extracts tells us if we send responses of the given Content-Type to the extractor
+func (f Filter) Extracts(contentType string) bool {
+	mediaType := MediaType(contentType)
+	return matches(f.Accept, mediaType) && matches(f.Extract, mediaType)
+}
+
+//IsText tells us if responses of the given Content-Type are text we can transcode
+func IsText(contentType string) bool {
+	mediaType := MediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "/xml") || strings.HasSuffix(mediaType, "+xml") ||
+		strings.HasSuffix(mediaType, "/json") || strings.HasSuffix(mediaType, "/javascript")
+}
+
+func matches(types []string, mediaType string) bool {
+	if mediaType == "" {
+		return false
+	}
+	for _, t := range types {
+		t = strings.ToLower(t)
+		if t == mediaType || t == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+			return true
+		}
+	}
+	return false
+}
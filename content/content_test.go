@@ -0,0 +1,65 @@
+package content
+
+import (
+	"testing"
+)
+
+func TestMediaType(t *testing.T) {
+	tests := map[string]string{
+		"text/html; charset=UTF-8": "text/html",
+		"Application/PDF":          "application/pdf",
+		"text/html;;":              "text/html",
+		"":                         "",
+	}
+	for header, expected := range tests {
+		if got := MediaType(header); got != expected {
+			t.Errorf("MediaType(%q) should be %q. It gave: %q\n", header, expected, got)
+		}
+	}
+}
+
+func TestDefaultFilter(t *testing.T) {
+	f := DefaultFilter
+	for _, ct := range []string{"text/html; charset=utf-8", "text/plain", "application/xhtml+xml"} {
+		if !f.Accepts(ct) {
+			t.Errorf("%s should be accepted\n", ct)
+		}
+	}
+	for _, ct := range []string{"application/pdf", "video/mp4", "application/octet-stream", ""} {
+		if f.Accepts(ct) {
+			t.Errorf("%s should not be accepted\n", ct)
+		}
+	}
+	if !f.Extracts("text/html") || f.Extracts("text/plain") || f.Extracts("text/css") {
+		t.Errorf("Only html should be extracted\n")
+	}
+}
+
+func TestExtractNeedsAccept(t *testing.T) {
+	f := Filter{Accept: []string{"text/html"}, Extract: []string{"text/*", "application/pdf"}}
+	if f.Extracts("application/pdf") || !f.Extracts("text/html") {
+		t.Errorf("Only accepted types should be extracted\n")
+	}
+}
+
+func TestSniff(t *testing.T) {
+	if got := MediaType(Sniff([]byte("<!DOCTYPE html><html><body>hi</body></html>"))); got != "text/html" {
+		t.Errorf("Expected text/html. It gave: %s\n", got)
+	}
+	if got := MediaType(Sniff([]byte("%PDF-1.4"))); got != "application/pdf" {
+		t.Errorf("Expected application/pdf. It gave: %s\n", got)
+	}
+}
+
+func TestIsText(t *testing.T) {
+	for _, ct := range []string{"text/html; charset=utf-8", "application/xhtml+xml", "application/json"} {
+		if !IsText(ct) {
+			t.Errorf("%s should be text\n", ct)
+		}
+	}
+	for _, ct := range []string{"application/pdf", "image/png"} {
+		if IsText(ct) {
+			t.Errorf("%s should not be text\n", ct)
+		}
+	}
+}
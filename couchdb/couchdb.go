@@ -5,24 +5,30 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"flag"
+	"fmt"
 	"github.com/fmpwizard/owlcrawler/parse"
+	"github.com/fmpwizard/owlcrawler/queue"
+	"github.com/fmpwizard/owlcrawler/recrawl"
+	"github.com/fmpwizard/owlcrawler/scope"
 	log "github.com/golang/glog"
 	"io/ioutil"
 	"net/http"
-	"os/user"
-	"path/filepath"
+	neturl "net/url"
 	"time"
 )
 
-var couchdbCredentials couchdbCred
-
-type couchdbCred struct {
+//Credentials tell us where the database is and how to log in
+type Credentials struct {
 	User     string
 	Password string
 	URL      string
 }
 
+//DB is a CouchDB database
+type DB struct {
+	cred Credentials
+}
+
 //CouchDoc represents a response fron CouchDB
 type CouchDoc struct {
 	ID           string              `json:"_id"`
@@ -30,10 +36,95 @@ type CouchDoc struct {
 	URL          string              `json:"url"`
 	HTML         string              `json:"html"`
 	Text         parse.PageStructure `json:"text"`
-	Links        []string            `json:"links,omitempty"`
+	Links        []parse.Link        `json:"links,omitempty"`
 	LinksToQueue []string            `json:"-"`
-	ParsedOn     time.Time           `json:"parsed_on,omitempty"`
+	ParsedOn     *time.Time          `json:"parsed_on,omitempty"`
 	FetchedOn    time.Time           `json:"fetched_on,omitempty"`
+	Depth        int                 `json:"depth,omitempty"`
+	Site         string              `json:"seed_site,omitempty"`
+	Referrer     string              `json:"referrer,omitempty"`
+	StatusCode   int                 `json:"status,omitempty"`
+	ETag         string              `json:"etag,omitempty"`
+	LastModified string              `json:"last_modified,omitempty"`
+	ContentHash  string              `json:"content_hash,omitempty"`
+	Revisit      *recrawl.History    `json:"revisit,omitempty"`
+	//ContentType and ContentLength come from the response, ContentLength is -1 when unknown
+	ContentType   string            `json:"content_type,omitempty"`
+	ContentLength int64             `json:"content_length,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	//Redirects are the hops we followed to get to FinalURL, in order
+	Redirects []Redirect `json:"redirects,omitempty"`
+	FinalURL  string     `json:"final_url,omitempty"`
+	//RedirectedTo is set when this url redirects to a page we store under its own url
+	RedirectedTo string `json:"redirected_to,omitempty"`
+	//Truncated is set when the page was longer than the max body size we read
+	Truncated bool `json:"truncated,omitempty"`
+	//Charset is the encoding the page came in, we store it as UTF-8
+	Charset string `json:"charset,omitempty"`
+	//Anchors is the text of the links other pages have to this one, search finds
+	//the page by it too
+	Anchors []string `json:"anchors,omitempty"`
+	//StructuredData are the items the page describes with JSON-LD, Microdata or RDFa
+	StructuredData []parse.Item `json:"structured_data,omitempty"`
+	//Directives come from the X-Robots-Tag headers and the robots meta tags of the page
+	parse.Directives
+}
+
+//Redirect is one hop we followed while fetching a page
+type Redirect struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+//BaseURL is the url relative links on the page are resolved against
+func (doc CouchDoc) BaseURL() string {
+	if doc.FinalURL != "" {
+		return doc.FinalURL
+	}
+	return doc.URL
+}
+
+//DuePage is a page that is due for a revisit
+type DuePage struct {
+	ID        string
+	URL       string
+	Site      string
+	Depth     int
+	NextVisit time.Time
+}
+
+type couchDueRet struct {
+	Rows []struct {
+		ID    string `json:"id"`
+		Value struct {
+			URL       string    `json:"url"`
+			Site      string    `json:"site"`
+			Depth     int       `json:"depth"`
+			NextVisit time.Time `json:"next_visit"`
+		} `json:"value"`
+	}
+}
+
+//DeadLetter is a url we gave up fetching
+type DeadLetter struct {
+	ID       string        `json:"_id"`
+	Rev      string        `json:"_rev,omitempty"`
+	URL      string        `json:"url"`
+	Reason   string        `json:"reason"`
+	Attempts int           `json:"attempts"`
+	FailedOn time.Time     `json:"failed_on"`
+	Message  queue.Message `json:"message"`
+}
+
+//DeadLetterID is the id of the dead letter document for url
+func DeadLetterID(url string) string {
+	return "dead-" + DocID(url)
+}
+
+type couchDeadLettersRet struct {
+	Rows []struct {
+		Doc DeadLetter `json:"doc"`
+	}
 }
 
 //CouchDocCreated represents a full document
@@ -46,6 +137,19 @@ type CouchDocCreated struct {
 //NewSite is used to add a new url submitted
 type NewSite struct {
 	Site string `json:"site"`
+	//CrawlDelay overrides the default seconds between requests to this site
+	CrawlDelay int `json:"crawl_delay,omitempty"`
+	//Scope limits which links we follow, scope.DefaultRules are used when empty
+	Scope *scope.Rules `json:"scope,omitempty"`
+}
+
+//CompileScope prepares the site's scope rules to check links against
+func (s NewSite) CompileScope() (*scope.Scope, error) {
+	rules := scope.DefaultRules
+	if s.Scope != nil {
+		rules = *s.Scope
+	}
+	return rules.Compile(s.Site)
 }
 
 type couchStatsRet struct {
@@ -67,21 +171,40 @@ var ErrorNoLatestVersion = errors.New("Not latest revision.")
 //Error404 the error you get when no document was found
 var Error404 = errors.New("Doc not found. ")
 
-func init() {
-	flag.Parse()
-	if u, err := user.Current(); err == nil {
-		path := filepath.Join(u.HomeDir, ".couchdb.json")
-		content, err := ioutil.ReadFile(path)
-		if err != nil {
-			log.Fatalf("Error reading Couchdb credentials, got: %v\n", err)
-		}
+//New gives us the database described by cred, creating our design documents if they are missing
+func New(cred Credentials) (*DB, error) {
+	if cred.URL == "" {
+		return nil, errors.New("Missing CouchDB url.")
+	}
+	if _, err := neturl.Parse(cred.URL); err != nil {
+		return nil, fmt.Errorf("Invalid CouchDB url %s, got: %v", cred.URL, err)
+	}
+	db := &DB{cred: cred}
+	if err := db.ping(); err != nil {
+		return nil, err
+	}
+	db.initDesignDocs()
+	return db, nil
+}
 
-		err = json.Unmarshal(content, &couchdbCredentials)
-		if err != nil {
-			log.Fatalf("Invalid Couchdb credentials file, got: %v\n", err)
-		}
+//ping checks we can reach the database
+func (db *DB) ping() error {
+	client := &http.Client{}
+	req, err := http.NewRequest("HEAD", db.cred.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
+	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Could not reach Couchdb at %s, got: %v", db.cred.URL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("Couchdb database %s gave status: %d", db.cred.URL, resp.StatusCode)
 	}
-	initDesignDocs()
+	return nil
 }
 
 var designSearch = []byte(`
@@ -108,23 +231,81 @@ var designReports = []byte(`
    "language": "javascript"
 }`)
 
-func initDesignDocs() {
-	if !isDocPresent("_design/reports", false) {
-		saveDesignDoc(designReports, "_design/reports")
+//designRecrawl lists the pages of each site by the time they should be fetched again,
+//and the sites we fetched pages of. Pages fetched before we kept a revisit history are due right away.
+//The time is cut to the second, so keys are all the same width and sort as times do.
+//We store times in UTC, so they all end in Z
+var designRecrawl = []byte(`
+{
+   "views": {
+       "due": {
+           "map": "function(doc) { if (doc.fetched_on && doc.url) { var next = (doc.revisit && doc.revisit.next_visit) ? doc.revisit.next_visit : doc.fetched_on; emit([doc.seed_site || '', next.substr(0, 19)], {url: doc.url, site: doc.seed_site, depth: doc.depth, next_visit: next}); } }"
+       },
+       "sites": {
+           "map": "function(doc) { if (doc.fetched_on && doc.url) { emit(doc.seed_site || '', 1); } }",
+           "reduce": "_count"
+       }
+   },
+   "language": "javascript"
+}`)
+
+var designDeadLetters = []byte(`
+{
+   "views": {
+       "all": {
+           "map": "function(doc) { if (doc.failed_on && doc.message) { emit(doc.failed_on, null); } }"
+       }
+   },
+   "language": "javascript"
+}`)
+
+//designStructured lists the structured data items of every page by their type,
+//e.g. _view/by_type?key="Product" gives the properties of all products
+var designStructured = []byte(`
+{
+   "views": {
+       "by_type": {
+           "map": "function(doc) { if (doc.url && doc.structured_data) { doc.structured_data.forEach(function(item) { (item.type || []).forEach(function(type) { emit(type, {url: doc.url, id: item.id, source: item.source, properties: item.properties}); }); }); } }"
+       }
+   },
+   "language": "javascript"
+}`)
+
+func (db *DB) initDesignDocs() {
+	if !db.isDocPresent("_design/feeds", false) {
+		db.saveDesignDoc(designFeeds, "_design/feeds")
+	}
+	if !db.isDocPresent("_design/links", false) {
+		db.saveDesignDoc(designLinks, "_design/links")
+	}
+	if !db.isDocPresent("_design/structured", false) {
+		db.saveDesignDoc(designStructured, "_design/structured")
+	}
+	if !db.isDocPresent("_design/frontier", false) {
+		db.saveDesignDoc(designFrontier, "_design/frontier")
 	}
-	if !isDocPresent("_design/search", false) {
-		saveDesignDoc(designSearch, "_design/search")
+	if !db.isDocPresent("_design/deadletters", false) {
+		db.saveDesignDoc(designDeadLetters, "_design/deadletters")
+	}
+	if !db.isDocPresent("_design/recrawl", false) {
+		db.saveDesignDoc(designRecrawl, "_design/recrawl")
+	}
+	if !db.isDocPresent("_design/reports", false) {
+		db.saveDesignDoc(designReports, "_design/reports")
+	}
+	if !db.isDocPresent("_design/search", false) {
+		db.saveDesignDoc(designSearch, "_design/search")
 	}
 }
 
-func saveDesignDoc(doc []byte, id string) {
+func (db *DB) saveDesignDoc(doc []byte, id string) {
 	client := &http.Client{}
 	document := bytes.NewReader(doc)
-	req, err := http.NewRequest("PUT", couchdbCredentials.URL+"/"+id, document)
+	req, err := http.NewRequest("PUT", db.cred.URL+"/"+id, document)
 	if err != nil {
 		log.Errorf("Error parsing url, got: %v\n", err)
 	}
-	req.SetBasicAuth(couchdbCredentials.User, couchdbCredentials.Password)
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
 	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
@@ -140,20 +321,18 @@ func saveDesignDoc(doc []byte, id string) {
 }
 
 //AddURLData adds the url and data to the database. data is json encoded.
-func AddURLData(url string, data []byte, mainURL bool) (CouchDocCreated, error) {
+func (db *DB) AddURLData(url string, data []byte, mainURL bool) (CouchDocCreated, error) {
 	client := &http.Client{}
 	document := bytes.NewReader(data)
-	id := ""
+	id := DocID(url)
 	if mainURL {
-		id = "site-" + base64.URLEncoding.EncodeToString([]byte(url))
-	} else {
-		id = base64.URLEncoding.EncodeToString([]byte(url))
+		id = "site-" + id
 	}
-	req, err := http.NewRequest("PUT", couchdbCredentials.URL+"/"+id, document)
+	req, err := http.NewRequest("PUT", db.cred.URL+"/"+id, document)
 	if err != nil {
 		log.Errorf("Error parsing url, got: %v\n", err)
 	}
-	req.SetBasicAuth(couchdbCredentials.User, couchdbCredentials.Password)
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
 	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
@@ -180,14 +359,14 @@ func AddURLData(url string, data []byte, mainURL bool) (CouchDocCreated, error)
 
 //SaveExtractedTextAndLinks updates the document with extraced information
 //like text and links
-func SaveExtractedTextAndLinks(id string, data []byte) (CouchDocCreated, error) {
+func (db *DB) SaveExtractedTextAndLinks(id string, data []byte) (CouchDocCreated, error) {
 	client := &http.Client{}
 	document := bytes.NewReader(data)
-	req, err := http.NewRequest("PUT", couchdbCredentials.URL+"/"+id, document)
+	req, err := http.NewRequest("PUT", db.cred.URL+"/"+id, document)
 	if err != nil {
 		log.Errorf("Error parsing url, got: %v\n", err)
 	}
-	req.SetBasicAuth(couchdbCredentials.User, couchdbCredentials.Password)
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
 	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
@@ -213,16 +392,21 @@ func SaveExtractedTextAndLinks(id string, data []byte) (CouchDocCreated, error)
 	return ret, nil
 }
 
+//UpdateURLData replaces a page we fetched before, data has to include its latest _rev
+func (db *DB) UpdateURLData(id string, data []byte) (CouchDocCreated, error) {
+	return db.SaveExtractedTextAndLinks(id, data)
+}
+
 //GetURLData gets the data stored in Couch, does a lookup by doc id
-func GetURLData(id string) (CouchDoc, error) {
+func (db *DB) GetURLData(id string) (CouchDoc, error) {
 	client := &http.Client{}
-	docURL := couchdbCredentials.URL + "/" + id
+	docURL := db.cred.URL + "/" + id
 	req, err := http.NewRequest("GET", docURL, nil)
 	if err != nil {
 		log.Errorf("Error parsing url, got: %v\n", err)
 		return CouchDoc{}, err
 	}
-	req.SetBasicAuth(couchdbCredentials.User, couchdbCredentials.Password)
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
 	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
 	req.Header.Set("Accept", "application/json")
 	resp, err := client.Do(req)
@@ -250,24 +434,40 @@ func GetURLData(id string) (CouchDoc, error) {
 	return result, nil
 }
 
+//GetSite gets the site document for a url submitted through the webapp
+func (db *DB) GetSite(url string) (NewSite, error) {
+	var site NewSite
+	err := db.getDoc("site-"+DocID(url), &site)
+	return site, err
+}
+
+//DocID is the id of the document that stores the given url.
+//The url is normalized first so different spellings of it share one document
+func DocID(url string) string {
+	if normalized, err := parse.NormalizeURL(url); err == nil {
+		url = normalized
+	}
+	return base64.URLEncoding.EncodeToString([]byte(url))
+}
+
 //ShouldURLBeFetched checks if the given url is already stored in the database
-func ShouldURLBeFetched(target string) bool {
-	return !isDocPresent(target, true)
+func (db *DB) ShouldURLBeFetched(target string) bool {
+	return !db.isDocPresent(target, true)
 }
 
-func isDocPresent(target string, encode bool) bool {
+func (db *DB) isDocPresent(target string, encode bool) bool {
 	client := &http.Client{}
 	url := ""
 	if encode {
-		url = couchdbCredentials.URL + "/" + base64.URLEncoding.EncodeToString([]byte(target))
+		url = db.cred.URL + "/" + DocID(target)
 	} else {
-		url = couchdbCredentials.URL + "/" + target
+		url = db.cred.URL + "/" + target
 	}
 	req, err := http.NewRequest("HEAD", url, nil)
 	if err != nil {
 		log.Errorf("Error parsing url, got: %v\n", err)
 	}
-	req.SetBasicAuth(couchdbCredentials.User, couchdbCredentials.Password)
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
 	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
 	req.Header.Set("Accept", "application/json")
 	resp, err := client.Do(req)
@@ -280,18 +480,18 @@ func isDocPresent(target string, encode bool) bool {
 }
 
 //IsItParsed checks if the given url is already parsed
-func IsItParsed(path string) bool {
+func (db *DB) IsItParsed(path string) bool {
 	var doc CouchDoc
-	json.Unmarshal(fetchData(path), &doc)
+	json.Unmarshal(db.fetchData(path), &doc)
 	log.V(4).Infof("Checking %s and got %t\n", path, len(doc.Text.Text) > 0)
 	return len(doc.Text.Text) > 0
 }
 
 //IndexStats returns stats related to the index, cnt of parsed/fetched/etc
-func IndexStats() *StatsIndex {
+func (db *DB) IndexStats() *StatsIndex {
 	path := "_design/reports/_view/stats?group=true&group_level=1"
 	var stat couchStatsRet
-	json.Unmarshal(fetchData(path), &stat)
+	json.Unmarshal(db.fetchData(path), &stat)
 	ret := &StatsIndex{}
 	for _, value := range stat.Rows {
 		if value.Key == "fetched_on" {
@@ -303,21 +503,141 @@ func IndexStats() *StatsIndex {
 	}
 
 	path = "_design/reports/_view/sites"
-	json.Unmarshal(fetchData(path), &stat)
+	json.Unmarshal(db.fetchData(path), &stat)
 	for _, row := range stat.Rows {
 		ret.Sites = append(ret.Sites, row.Key)
 	}
 	return ret
 }
 
-func fetchData(path string) []byte {
+//dueKeyLayout is how the due view writes the time a page is due in its keys
+const dueKeyLayout = "2006-01-02T15:04:05"
+
+//DuePages lists up to limit pages of site that should have been revisited before the given time
+func (db *DB) DuePages(site string, before time.Time, limit int) ([]DuePage, error) {
+	startKey, err := json.Marshal([]interface{}{site})
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := json.Marshal([]interface{}{site, before.UTC().Format(dueKeyLayout)})
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("_design/recrawl/_view/due?startkey=%s&endkey=%s&limit=%d",
+		neturl.QueryEscape(string(startKey)), neturl.QueryEscape(string(endKey)), limit)
+	var due couchDueRet
+	if err := json.Unmarshal(db.fetchData(path), &due); err != nil {
+		return nil, err
+	}
+	var ret []DuePage
+	for _, row := range due.Rows {
+		ret = append(ret, DuePage{
+			ID:        row.ID,
+			URL:       row.Value.URL,
+			Site:      row.Value.Site,
+			Depth:     row.Value.Depth,
+			NextVisit: row.Value.NextVisit,
+		})
+	}
+	return ret, nil
+}
+
+//PageSites lists the sites we fetched pages of, pages without a site are listed under ""
+func (db *DB) PageSites() ([]string, error) {
+	var sites couchDepthRet
+	if err := json.Unmarshal(db.fetchData("_design/recrawl/_view/sites?group=true"), &sites); err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, row := range sites.Rows {
+		ret = append(ret, row.Key)
+	}
+	return ret, nil
+}
+
+//AddDeadLetter records a url we gave up fetching, replacing an older record of it
+func (db *DB) AddDeadLetter(letter DeadLetter) error {
+	letter.ID = DeadLetterID(letter.URL)
+	var old DeadLetter
+	if err := db.getDoc(letter.ID, &old); err == nil {
+		letter.Rev = old.Rev
+	}
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	_, err = db.SaveExtractedTextAndLinks(letter.ID, data)
+	return err
+}
+
+//DeadLetters lists up to limit urls we gave up fetching, the latest first
+func (db *DB) DeadLetters(limit int) ([]DeadLetter, error) {
+	path := fmt.Sprintf("_design/deadletters/_view/all?descending=true&include_docs=true&limit=%d", limit)
+	var letters couchDeadLettersRet
+	if err := json.Unmarshal(db.fetchData(path), &letters); err != nil {
+		return nil, err
+	}
+	var ret []DeadLetter
+	for _, row := range letters.Rows {
+		ret = append(ret, row.Doc)
+	}
+	return ret, nil
+}
+
+//GetDeadLetter does a lookup by dead letter id
+func (db *DB) GetDeadLetter(id string) (DeadLetter, error) {
+	var letter DeadLetter
+	err := db.getDoc(id, &letter)
+	return letter, err
+}
+
+//RemoveDeadLetter deletes the dead letter, once it's requeued
+func (db *DB) RemoveDeadLetter(id string) error {
+	return db.deleteDoc(id)
+}
+
+//deleteDoc deletes the latest revision of the document with the given id
+func (db *DB) deleteDoc(id string) error {
+	var doc struct {
+		Rev string `json:"_rev"`
+	}
+	if err := db.getDoc(id, &doc); err != nil {
+		return err
+	}
+	client := &http.Client{}
+	req, err := http.NewRequest("DELETE", db.cred.URL+"/"+id+"?rev="+neturl.QueryEscape(doc.Rev), nil)
+	if err != nil {
+		log.Errorf("Error parsing url, got: %v\n", err)
+		return err
+	}
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
+	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Errorf("Error sending request to Couchdb, got: %v\n", err)
+		return err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case 200, 202:
+		return nil
+	case 404:
+		return Error404
+	case 409:
+		return ErrorNoLatestVersion
+	}
+	return fmt.Errorf("Deleting %s gave status: %d", id, resp.StatusCode)
+}
+
+func (db *DB) fetchData(path string) []byte {
 	client := &http.Client{}
-	url := couchdbCredentials.URL + "/" + path
+	url := db.cred.URL + "/" + path
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		log.Errorf("Error parsing parsedCnt design view, got: %v\n", err)
 	}
-	req.SetBasicAuth(couchdbCredentials.User, couchdbCredentials.Password)
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
 	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
 	req.Header.Set("Accept", "application/json")
 	resp, err := client.Do(req)
@@ -331,3 +651,31 @@ func fetchData(path string) []byte {
 	body, _ := ioutil.ReadAll(resp.Body)
 	return body
 }
+
+//getDoc reads the document with the given id into v
+func (db *DB) getDoc(id string, v interface{}) error {
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", db.cred.URL+"/"+id, nil)
+	if err != nil {
+		log.Errorf("Error parsing url, got: %v\n", err)
+		return err
+	}
+	req.SetBasicAuth(db.cred.User, db.cred.Password)
+	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Errorf("Error sending request to Couchdb, got: %v\n", err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return Error404
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.Errorf("Error reading body response for %s, got: %v\n", id, err)
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,88 @@
+package couchdb
+
+import (
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"time"
+)
+
+//Feed is an RSS or Atom feed we found on a site, and poll for new pages
+type Feed struct {
+	ID    string `json:"_id"`
+	Rev   string `json:"_rev,omitempty"`
+	URL   string `json:"feed_url"`
+	Site  string `json:"seed_site,omitempty"`
+	Title string `json:"title,omitempty"`
+	//PolledOn is the last time we read the feed, NextPoll when we read it again
+	PolledOn     time.Time `json:"polled_on"`
+	NextPoll     time.Time `json:"next_poll"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	//Entries is how many entries the feed had last time
+	Entries int `json:"entries"`
+}
+
+//FeedID is the id of the document of the feed at url
+func FeedID(url string) string {
+	return "feed-" + DocID(url)
+}
+
+//designFeeds lists the feeds by the time we should read them again
+var designFeeds = []byte(`
+{
+   "views": {
+       "due": {
+           "map": "function(doc) { if (doc.feed_url && doc.next_poll) { emit(doc.next_poll, null); } }"
+       }
+   },
+   "language": "javascript"
+}`)
+
+type couchFeedsRet struct {
+	Rows []struct {
+		Doc Feed `json:"doc"`
+	}
+}
+
+//SaveFeed records feed, replacing what we had about it
+func (db *DB) SaveFeed(feed Feed) error {
+	feed.ID = FeedID(feed.URL)
+	var old Feed
+	if err := db.getDoc(feed.ID, &old); err == nil {
+		feed.Rev = old.Rev
+	} else {
+		feed.Rev = ""
+	}
+	data, err := json.Marshal(feed)
+	if err != nil {
+		return err
+	}
+	_, err = db.SaveExtractedTextAndLinks(feed.ID, data)
+	return err
+}
+
+//GetFeed does a lookup by feed id
+func (db *DB) GetFeed(id string) (Feed, error) {
+	var feed Feed
+	err := db.getDoc(id, &feed)
+	return feed, err
+}
+
+//DueFeeds lists up to limit feeds we should have read again before the given time
+func (db *DB) DueFeeds(before time.Time, limit int) ([]Feed, error) {
+	endKey, err := json.Marshal(before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("_design/feeds/_view/due?include_docs=true&endkey=%s&limit=%d", neturl.QueryEscape(string(endKey)), limit)
+	var due couchFeedsRet
+	if err := json.Unmarshal(db.fetchData(path), &due); err != nil {
+		return nil, err
+	}
+	var ret []Feed
+	for _, row := range due.Rows {
+		ret = append(ret, row.Doc)
+	}
+	return ret, nil
+}
@@ -0,0 +1,126 @@
+package couchdb
+
+import (
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/queue"
+)
+
+//FrontierEntry is a url waiting in the fetch queue. It stays in the database
+//until a fetcher is done with it, and goes back to the queue if nobody is
+//done with it by VisibleAt
+type FrontierEntry struct {
+	ID         string        `json:"_id"`
+	Rev        string        `json:"_rev,omitempty"`
+	URL        string        `json:"url"`
+	Site       string        `json:"seed_site,omitempty"`
+	QueuedOn   time.Time     `json:"queued_on"`
+	VisibleAt  time.Time     `json:"visible_at"`
+	Deliveries int           `json:"deliveries"`
+	Message    queue.Message `json:"message"`
+}
+
+//FrontierID is the id of the frontier entry for url
+func FrontierID(url string) string {
+	return "queue-" + DocID(url)
+}
+
+//designFrontier lists the frontier entries by the time they go back to the queue,
+//and counts them per site
+var designFrontier = []byte(`
+{
+   "views": {
+       "due": {
+           "map": "function(doc) { if (doc.visible_at && doc.message) { emit(doc.visible_at, null); } }"
+       },
+       "depth": {
+           "map": "function(doc) { if (doc.visible_at && doc.message) { emit(doc.seed_site || '', 1); } }",
+           "reduce": "_count"
+       }
+   },
+   "language": "javascript"
+}`)
+
+type couchFrontierRet struct {
+	Rows []struct {
+		Doc FrontierEntry `json:"doc"`
+	}
+}
+
+type couchDepthRet struct {
+	Rows []struct {
+		Key   string `json:"key"`
+		Value int    `json:"value"`
+	}
+}
+
+//Enqueue adds entry to the frontier, telling us if it wasn't there already
+func (db *DB) Enqueue(entry FrontierEntry) (bool, error) {
+	entry.ID = FrontierID(entry.URL)
+	entry.Rev = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return false, err
+	}
+	_, err = db.SaveExtractedTextAndLinks(entry.ID, data)
+	if err == ErrorNoLatestVersion {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+//UpdateFrontier saves entry, which has to have its latest _rev
+func (db *DB) UpdateFrontier(entry FrontierEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = db.SaveExtractedTextAndLinks(entry.ID, data)
+	return err
+}
+
+//GetFrontier does a lookup by frontier entry id
+func (db *DB) GetFrontier(id string) (FrontierEntry, error) {
+	var entry FrontierEntry
+	err := db.getDoc(id, &entry)
+	return entry, err
+}
+
+//RemoveFrontier takes the entry out of the frontier, once a fetcher is done with it
+func (db *DB) RemoveFrontier(id string) error {
+	return db.deleteDoc(id)
+}
+
+//DueFrontier lists up to limit entries that should be back in the queue before the given time
+func (db *DB) DueFrontier(before time.Time, limit int) ([]FrontierEntry, error) {
+	endKey, err := json.Marshal(before.UTC())
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("_design/frontier/_view/due?include_docs=true&endkey=%s&limit=%d", neturl.QueryEscape(string(endKey)), limit)
+	var due couchFrontierRet
+	if err := json.Unmarshal(db.fetchData(path), &due); err != nil {
+		return nil, err
+	}
+	var ret []FrontierEntry
+	for _, row := range due.Rows {
+		ret = append(ret, row.Doc)
+	}
+	return ret, nil
+}
+
+//FrontierDepth counts the urls waiting in the frontier, per site
+func (db *DB) FrontierDepth() (map[string]int, error) {
+	var depth couchDepthRet
+	if err := json.Unmarshal(db.fetchData("_design/frontier/_view/depth?group=true"), &depth); err != nil {
+		return nil, err
+	}
+	ret := make(map[string]int)
+	for _, row := range depth.Rows {
+		ret[row.Key] = row.Value
+	}
+	return ret, nil
+}
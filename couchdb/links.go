@@ -0,0 +1,50 @@
+package couchdb
+
+import (
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+)
+
+//Inlink is a link another page has to the one we look at
+type Inlink struct {
+	From     string `json:"from"`
+	Text     string `json:"text,omitempty"`
+	NoFollow bool   `json:"nofollow,omitempty"`
+}
+
+//designLinks lists the links pages have by the url they point to, which gives us
+//the link graph the other way around. Older documents only have plain urls as links
+var designLinks = []byte(`
+{
+   "views": {
+       "inbound": {
+           "map": "function(doc) { if (doc.url && doc.links) { for (var i in doc.links) { var link = doc.links[i]; var url = typeof link == 'string' ? link : link.url; if (url && url != doc.url) { emit(url, {from: doc.url, text: link.text, nofollow: link.nofollow}); } } } }"
+       }
+   },
+   "language": "javascript"
+}`)
+
+type couchInlinksRet struct {
+	Rows []struct {
+		Value Inlink `json:"value"`
+	}
+}
+
+//Inlinks lists up to limit links other pages have to url
+func (db *DB) Inlinks(url string, limit int) ([]Inlink, error) {
+	key, err := json.Marshal(url)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("_design/links/_view/inbound?key=%s&limit=%d", neturl.QueryEscape(string(key)), limit)
+	var inbound couchInlinksRet
+	if err := json.Unmarshal(db.fetchData(path), &inbound); err != nil {
+		return nil, err
+	}
+	var ret []Inlink
+	for _, row := range inbound.Rows {
+		ret = append(ret, row.Value)
+	}
+	return ret, nil
+}
@@ -18,7 +18,7 @@ type ElasticSearchDoc struct {
 	URL          string              `json:"url"`
 	HTML         string              `json:"html"`
 	Text         parse.PageStructure `json:"text"`
-	Links        []string            `json:"links"`
+	Links        []parse.Link        `json:"links"`
 	LinksToQueue []string            `json:"-"`
 }
 
@@ -56,7 +56,7 @@ type source struct {
 	URL   string              `json:"url"`
 	HTML  string              `json:"html"`
 	Text  parse.PageStructure `json:"text"`
-	Links []string            `json:"links"`
+	Links []parse.Link        `json:"links"`
 }
 
 type highlight struct {
@@ -113,12 +113,21 @@ func highlightJson(term string) string {
 {
     
     "query" : {
-        "match" : {
-	        "_all" : {
-	            "query" : "%s",
-	            "type" : "phrase"
-	        }
-	    }
+        "bool" : {
+            "must" : {
+                "match" : {
+                    "_all" : {
+                        "query" : "%s",
+                        "type" : "phrase"
+                    }
+                }
+            },
+            "must_not" : {
+                "term" : {
+                    "noindex" : true
+                }
+            }
+        }
     },
     "highlight" : {
     	"pre_tags" : ["_-_strong_-_"],
@@ -6,58 +6,169 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/fmpwizard/owlcrawler/config"
 	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/frontier"
 	"github.com/fmpwizard/owlcrawler/parse"
+	"github.com/fmpwizard/owlcrawler/queue"
+	"github.com/fmpwizard/owlcrawler/scope"
+	"github.com/fmpwizard/owlcrawler/seen"
+	"github.com/fmpwizard/owlcrawler/store"
 	log "github.com/golang/glog"
 	"github.com/nats-io/nats"
-	"io/ioutil"
-	"os/user"
-	"path/filepath"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
 const extractQueue = "extract_url"
-const fetchQueue = "fetch_url"
+
+//robotsAgent is the name we look for in robots meta tags, besides "robots"
+const robotsAgent = "OwlCrawler"
 
 var fn = func(url string) bool {
-	return couchdb.ShouldURLBeFetched(url)
+	return !seenURLs.Seen(url)
 }
-var gnatsdCredentials gnatsdCred
+var configLoader = config.Flags(flag.CommandLine)
+var cfg *config.Config
+var db store.Store
 
-type gnatsdCred struct {
-	URL string
-}
+//nc is the gnatsd connection we receive pages and send links on
+var nc *nats.Conn
+
+//urlFrontier keeps the links we send to the fetchers until they are done with them
+var urlFrontier *frontier.Frontier
 
-func extractText(id string) {
+//seenURLs are the links we, or other extractors, already sent to the fetchers
+var seenURLs *seen.Set
+
+func extractText(msg queue.Message) {
+	id := msg.DocID
 	doc, err := getStoredHTMLForDocID(id)
-	if err == nil {
-		err = saveExtractedData(extractData(doc))
-		if err == couchdb.ErrorNoLatestVersion {
-			doc, err = getStoredHTMLForDocID(id)
-			if err != nil {
-				log.Errorf("Failed to get latest version of %s\n", id)
-				return
-			}
-			saveExtractedData(extractData(doc))
+	if err != nil {
+		log.V(2).Infof("Finished extracting text for %s\n", id)
+		return
+	}
+	extracted := extractData(doc, msg)
+	err = saveExtractedData(extracted)
+	if err == couchdb.ErrorNoLatestVersion {
+		doc, err = getStoredHTMLForDocID(id)
+		if err != nil {
+			log.Errorf("Failed to get latest version of %s\n", id)
+			return
 		}
+		extracted = extractData(doc, msg)
+		err = saveExtractedData(extracted)
+	}
+	if err != nil {
+		log.Errorf("Failed to save the extracted data of %s, got: %v\n", id, err)
+		return
 	}
+	queueLinks(extracted, msg)
 	log.V(2).Infof("Finished extracting text for %s\n", id)
 }
 
-func extractData(doc couchdb.CouchDoc) couchdb.CouchDoc {
+//extractData fills in what we extract from the page in doc, it doesn't queue anything
+//so it can run again when saving doc fails
+func extractData(doc couchdb.CouchDoc, msg queue.Message) couchdb.CouchDoc {
+	page := pageMessage(doc, msg)
 	doc.Text = parse.ExtractText(doc.HTML)
-	fetch, storing := parse.ExtractLinks(doc.HTML, doc.URL, fn)
+	doc.Text.Meta = parse.ExtractMeta(doc.HTML, doc.BaseURL())
+	doc.StructuredData = parse.ExtractStructuredData(doc.HTML, doc.BaseURL())
+	doc.Directives = doc.Directives.Merge(parse.MetaRobots(doc.HTML, robotsAgent))
+	siteScope := scopeFor(page)
+	inScope := func(url string) bool {
+		if doc.NoFollow {
+			log.V(3).Infof("Not fetching %s, %s is nofollow\n", url, doc.URL)
+			return false
+		}
+		if ok, reason := siteScope.Allows(url, page.Depth+1); !ok {
+			log.V(3).Infof("Not fetching %s, %s\n", url, reason)
+			return false
+		}
+		return fn(url)
+	}
+	fetch, storing := parse.ExtractLinks(doc.HTML, doc.BaseURL(), inScope)
 	doc.LinksToQueue = fetch.URL
-	doc.Links = storing.URL
-	doc.ParsedOn = time.Now().UTC()
-	nc, err := nats.Connect(gnatsdCredentials.URL)
+	doc.Links = storing.Links
+	doc.Anchors = anchorTexts(doc.URL)
+	parsedOn := time.Now().UTC()
+	doc.ParsedOn = &parsedOn
+	return doc
+}
+
+//queueLinks sends the links and feeds of a page we saved to the fetchers
+func queueLinks(doc couchdb.CouchDoc, msg queue.Message) {
+	page := pageMessage(doc, msg)
+	for _, u := range doc.LinksToQueue {
+		if err := urlFrontier.Push(page.Child(u)); err != nil {
+			log.Errorf("Failed to push %s to fetch queue, got: %v\n", u, err)
+			continue
+		}
+		seenURLs.Add(u)
+	}
+	if !doc.NoFollow {
+		queueFeeds(doc, page)
+	}
+}
+
+//anchorTexts collects the text of the links other pages have to url, leaving out
+//nofollow links and repeated texts
+func anchorTexts(url string) []string {
+	texts, err := store.AnchorTexts(db, url)
 	if err != nil {
-		log.Fatalf("Could not connect to gnatsd, got: %s\n", err)
+		log.Errorf("Error getting the links to %s, got: %v\n", url, err)
 	}
-	for _, u := range fetch.URL {
-		nc.Publish(fetchQueue, []byte(u))
+	return texts
+}
+
+//queueFeeds sends the feeds the page announces to the fetchers, the first time we see them.
+//From then on the recrawler polls them
+func queueFeeds(doc couchdb.CouchDoc, page queue.Message) {
+	for _, u := range parse.ExtractFeeds(doc.HTML, doc.BaseURL()) {
+		if seenURLs.Seen(u) {
+			continue
+		}
+		msg := queue.Message{
+			URL:      u,
+			Referrer: page.URL,
+			Depth:    page.Depth,
+			Site:     page.Site,
+			Priority: queue.PriorityHigh,
+			Kind:     queue.KindFeed,
+		}
+		if err := urlFrontier.Push(msg); err != nil {
+			log.Errorf("Failed to push feed %s to fetch queue, got: %v\n", u, err)
+			continue
+		}
+		seenURLs.Add(u)
 	}
-	return doc
+}
+
+//pageMessage describes the page stored in doc, the metadata stored by the fetcher
+//fills in what older messages don't carry
+func pageMessage(doc couchdb.CouchDoc, msg queue.Message) queue.Message {
+	msg.URL = doc.URL
+	if msg.Site == "" {
+		msg.Site = doc.Site
+	}
+	if msg.Depth == 0 {
+		msg.Depth = doc.Depth
+	}
+	if msg.Referrer == "" {
+		msg.Referrer = doc.Referrer
+	}
+	return msg
+}
+
+//scopeFor gets the scope rules of the site the page belongs to
+func scopeFor(page queue.Message) *scope.Scope {
+	siteScope, err := store.SiteScope(db, page.Site, page.URL)
+	if err != nil {
+		log.Errorf("%v\n", err)
+	}
+	return siteScope
 }
 
 func saveExtractedData(doc couchdb.CouchDoc) error {
@@ -65,7 +176,7 @@ func saveExtractedData(doc couchdb.CouchDoc) error {
 	if err != nil {
 		return fmt.Errorf("Error generating json to save docWithText in database, got: %v\n", err)
 	}
-	ret, err := couchdb.SaveExtractedTextAndLinks(doc.ID, jsonDocWithExtractedData)
+	ret, err := db.SaveExtractedTextAndLinks(doc.ID, jsonDocWithExtractedData)
 	if err == couchdb.ErrorNoLatestVersion {
 		return couchdb.ErrorNoLatestVersion
 	}
@@ -79,7 +190,7 @@ func saveExtractedData(doc couchdb.CouchDoc) error {
 }
 
 func getStoredHTMLForDocID(id string) (couchdb.CouchDoc, error) {
-	doc, err := couchdb.GetURLData(id)
+	doc, err := db.GetURLData(id)
 	if err == couchdb.Error404 {
 		return doc, couchdb.Error404
 	}
@@ -91,34 +202,95 @@ func getStoredHTMLForDocID(id string) (couchdb.CouchDoc, error) {
 	return doc, nil
 }
 
+//openSeen loads the links we already queued, and keeps learning about the ones other processes queue
+func openSeen() (*seen.Set, error) {
+	filter := seen.NewBloom(cfg.Seen.Capacity, cfg.Seen.ErrorRate)
+	if cfg.Seen.File != "" {
+		var err error
+		if filter, err = seen.Load(cfg.Seen.File, cfg.Seen.Capacity, cfg.Seen.ErrorRate); err != nil {
+			return nil, err
+		}
+	}
+	set := seen.New(filter, nc)
+	if _, err := nc.Subscribe(seen.Subject, func(msg *nats.Msg) { set.Receive(msg.Data) }); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+//saveSeen writes the links we already queued to disk, for the next run
+func saveSeen() {
+	if cfg.Seen.File == "" {
+		return
+	}
+	if err := seenURLs.Save(cfg.Seen.File); err != nil {
+		log.Errorf("Error saving seen urls to %s, got: %v\n", cfg.Seen.File, err)
+	}
+}
+
+//shutdown stops taking pages from the extract queue and makes sure the links
+//we found reach gnatsd before we exit. main only calls it between pages, so
+//there is no extraction in flight
+func shutdown(sub *nats.Subscription) {
+	log.Infoln("Shutting down")
+	if err := sub.Unsubscribe(); err != nil {
+		log.Errorf("Error unsubscribing from %s, got: %v\n", extractQueue, err)
+	}
+	saveSeen()
+	if err := nc.FlushTimeout(cfg.ShutdownTimeout.Duration); err != nil {
+		log.Errorf("Error flushing messages to gnatsd, got: %v\n", err)
+	}
+	nc.Close()
+	log.Infoln("Extractor stopped")
+}
+
 func main() {
 	flag.Parse()
 	log.V(2).Infoln("Starting Extractor")
-	nc, _ := nats.Connect(gnatsdCredentials.URL)
+	var err error
+	cfg, err = configLoader.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration, got: %v\n", err)
+	}
+	db, err = store.Open(cfg)
+	if err != nil {
+		log.Fatalf("Could not open %s store, got: %v\n", cfg.Store, err)
+	}
+	nc, err = nats.Connect(cfg.Gnatsd.URL)
+	if err != nil {
+		log.Fatalf("Could not connect to gnatsd, got: %v\n", err)
+	}
+	urlFrontier = frontier.New(db, nc, cfg.Frontier.AckTimeout.Duration, cfg.Frontier.MaxDeliveries)
+	seenURLs, err = openSeen()
+	if err != nil {
+		log.Fatalf("Could not load seen urls from %s, got: %v\n", cfg.Seen.File, err)
+	}
 	sub, err := nc.QueueSubscribeSync(extractQueue, "extractor-pool")
 	if err != nil {
 		log.Fatalf("Error while subscribing to extract_url, got %s\n", err)
 	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	save := time.NewTicker(cfg.Seen.SaveEvery.Duration)
+	defer save.Stop()
 	for {
-		if payload, err := sub.NextMsg(30 * time.Second); err == nil {
-			if !couchdb.IsItParsed(string(payload.Data[:])) {
-				extractText(string(payload.Data[:]))
-			}
+		select {
+		case <-stop:
+			shutdown(sub)
+			return
+		case <-save.C:
+			saveSeen()
+		default:
 		}
-	}
-}
-
-func init() {
-	if u, err := user.Current(); err == nil {
-		path := filepath.Join(u.HomeDir, ".gnatsd.json")
-		content, err := ioutil.ReadFile(path)
-		if err != nil {
-			log.Fatalf("Error reading gnatds user file, got: %v\n", err)
-		}
-
-		err = json.Unmarshal(content, &gnatsdCredentials)
-		if err != nil {
-			log.Fatalf("Invalid gnatsd credentials file, got: %v\n", err)
+		if payload, err := sub.NextMsg(time.Second); err == nil {
+			msg, err := queue.DecodeExtract(payload.Data)
+			if err != nil {
+				log.Errorf("Dropping invalid message, got: %v\n", err)
+				continue
+			}
+			if !db.IsItParsed(msg.DocID) {
+				extractText(msg)
+			}
 		}
 	}
 }
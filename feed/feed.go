@@ -0,0 +1,164 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+//Entry is one item of an RSS feed or entry of an Atom feed
+type Entry struct {
+	URL   string
+	Title string
+	//Updated is when the entry was last changed or published, zero if the feed doesn't say
+	Updated time.Time
+}
+
+//Feed is what we read from an RSS 2.0 or Atom feed
+type Feed struct {
+	Title   string
+	Entries []Entry
+}
+
+type rss struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title string `xml:"title"`
+			Link  string `xml:"link"`
+			GUID  struct {
+				Value       string `xml:",chardata"`
+				IsPermaLink string `xml:"isPermaLink,attr"`
+			} `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+			//Date is the Dublin Core date some feeds use instead of pubDate
+			Date string `xml:"http://purl.org/dc/elements/1.1/ date"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomFeed struct {
+	Title   string `xml:"title"`
+	Entries []struct {
+		Title     string     `xml:"title"`
+		Links     []atomLink `xml:"link"`
+		Updated   string     `xml:"updated"`
+		Published string     `xml:"published"`
+	} `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+//dateFormats are the dates RSS (RFC 822, give or take) and Atom (RFC 3339) use
+var dateFormats = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 2006 15:04 -0700",
+}
+
+//Parse reads an RSS 2.0 or Atom feed. Relative entry links are resolved against feedURL
+func Parse(body []byte, feedURL string) (Feed, error) {
+	var ret Feed
+	base, err := url.Parse(feedURL)
+	if err != nil {
+		return ret, err
+	}
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := decode(body, &root); err != nil {
+		return ret, err
+	}
+	switch root.XMLName.Local {
+	case "rss":
+		var doc rss
+		if err := decode(body, &doc); err != nil {
+			return ret, err
+		}
+		ret.Title = strings.TrimSpace(doc.Channel.Title)
+		for _, item := range doc.Channel.Items {
+			link := item.Link
+			if strings.TrimSpace(link) == "" && item.GUID.IsPermaLink != "false" {
+				//A guid is the url of the item unless isPermaLink says otherwise
+				link = item.GUID.Value
+			}
+			date := item.PubDate
+			if date == "" {
+				date = item.Date
+			}
+			ret.add(base, link, item.Title, date)
+		}
+	case "feed":
+		var doc atomFeed
+		if err := decode(body, &doc); err != nil {
+			return ret, err
+		}
+		ret.Title = strings.TrimSpace(doc.Title)
+		for _, entry := range doc.Entries {
+			date := entry.Updated
+			if date == "" {
+				date = entry.Published
+			}
+			ret.add(base, alternate(entry.Links), entry.Title, date)
+		}
+	default:
+		return ret, fmt.Errorf("Not a feed, root element is %s", root.XMLName.Local)
+	}
+	return ret, nil
+}
+
+func (f *Feed) add(base *url.URL, link, title, date string) {
+	ref, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || ref.String() == "" {
+		return
+	}
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return
+	}
+	f.Entries = append(f.Entries, Entry{
+		URL:     resolved.String(),
+		Title:   strings.TrimSpace(title),
+		Updated: parseDate(date),
+	})
+}
+
+//alternate picks the link to the entry's page, the one with no rel or rel="alternate"
+func alternate(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, format := range dateFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+//decode reads xml in whatever encoding it declares
+func decode(body []byte, v interface{}) error {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	d.CharsetReader = charset.NewReaderLabel
+	d.Strict = false
+	return d.Decode(v)
+}
@@ -0,0 +1,75 @@
+package feed
+
+import (
+	"testing"
+	"time"
+)
+
+var rssFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0">
+  <channel>
+    <title>Owl news</title>
+    <item>
+      <title>Owls can fly</title>
+      <link>http://example.com/owls-can-fly</link>
+      <pubDate>Mon, 01 Jun 2015 08:30:00 +0000</pubDate>
+    </item>
+    <item>
+      <title>Caf` + "\xe9" + ` owls</title>
+      <guid>/cafe-owls</guid>
+    </item>
+    <item>
+      <title>No link</title>
+      <guid isPermaLink="false">urn:owl:3</guid>
+    </item>
+  </channel>
+</rss>`
+
+var atom = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Owl blog</title>
+  <entry>
+    <title>Night watch</title>
+    <link rel="edit" href="/edit/1"/>
+    <link rel="alternate" href="http://example.com/night-watch"/>
+    <updated>2015-06-01T08:30:00Z</updated>
+  </entry>
+  <entry>
+    <title>Draft</title>
+    <link href="javascript:void(0)"/>
+  </entry>
+</feed>`
+
+func TestParseRSS(t *testing.T) {
+	f, err := Parse([]byte(rssFeed), "http://example.com/feed.xml")
+	if err != nil {
+		t.Fatalf("Parse failed with: %v\n", err)
+	}
+	if f.Title != "Owl news" || len(f.Entries) != 2 {
+		t.Fatalf("Expected two entries. It gave: %+v\n", f)
+	}
+	first := f.Entries[0]
+	if first.URL != "http://example.com/owls-can-fly" || !first.Updated.Equal(time.Date(2015, 6, 1, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("Wrong first entry. It gave: %+v\n", first)
+	}
+	second := f.Entries[1]
+	if second.URL != "http://example.com/cafe-owls" || second.Title != "Café owls" || !second.Updated.IsZero() {
+		t.Errorf("Wrong second entry. It gave: %+v\n", second)
+	}
+}
+
+func TestParseAtom(t *testing.T) {
+	f, err := Parse([]byte(atom), "http://example.com/atom.xml")
+	if err != nil {
+		t.Fatalf("Parse failed with: %v\n", err)
+	}
+	if f.Title != "Owl blog" || len(f.Entries) != 1 {
+		t.Fatalf("Expected one entry. It gave: %+v\n", f)
+	}
+	if e := f.Entries[0]; e.URL != "http://example.com/night-watch" || e.Updated.IsZero() {
+		t.Errorf("Wrong entry. It gave: %+v\n", e)
+	}
+	if _, err := Parse([]byte("<html></html>"), "http://example.com/"); err == nil {
+		t.Errorf("Html pages are not feeds\n")
+	}
+}
@@ -3,118 +3,1234 @@
 package main
 
 import (
-	"encoding/base64"
+	"crypto/sha1"
 	"encoding/json"
 	"flag"
+	"fmt"
+	"github.com/fmpwizard/owlcrawler/config"
+	"github.com/fmpwizard/owlcrawler/content"
 	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/feed"
+	"github.com/fmpwizard/owlcrawler/frontier"
+	"github.com/fmpwizard/owlcrawler/parse"
+	"github.com/fmpwizard/owlcrawler/politeness"
+	"github.com/fmpwizard/owlcrawler/queue"
+	"github.com/fmpwizard/owlcrawler/recrawl"
+	"github.com/fmpwizard/owlcrawler/retry"
+	"github.com/fmpwizard/owlcrawler/robots"
+	"github.com/fmpwizard/owlcrawler/seen"
+	"github.com/fmpwizard/owlcrawler/sitemap"
+	"github.com/fmpwizard/owlcrawler/store"
 	log "github.com/golang/glog"
 	"github.com/nats-io/nats"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
-	"os/user"
-	"path/filepath"
+	neturl "net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
-const fetchQueue = "fetch_url"
 const extractQueue = "extract_url"
 
+//deadLetterQueue gets the urls we gave up fetching
+const deadLetterQueue = "dead_url"
+const userAgent = "OwlCrawler - https://github.com/fmpwizard/owlcrawler"
+
+//robotsAgent is the name we look for in robots.txt User-agent lines and X-Robots-Tag headers
+const robotsAgent = "OwlCrawler"
+
+//robotsRetryDelay is how long we wait before trying a url again when we could not get its robots.txt
+const robotsRetryDelay = 10 * time.Minute
+
 type dataStore struct {
 	ID        string    `json:"_id"`
 	URL       string    `json:"url"`
 	HTML      string    `json:"html"`
 	FetchedOn time.Time `json:"fetched_on"`
+	Depth     int       `json:"depth,omitempty"`
+	Site      string    `json:"seed_site,omitempty"`
+	Referrer  string    `json:"referrer,omitempty"`
+	//Rev is only set when we replace a page we fetched before
+	Rev          string `json:"_rev,omitempty"`
+	StatusCode   int    `json:"status,omitempty"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	ContentHash  string `json:"content_hash,omitempty"`
+	//Revisit tracks how often the page changes, to know when to fetch it again
+	Revisit       *recrawl.History   `json:"revisit,omitempty"`
+	ContentType   string             `json:"content_type,omitempty"`
+	ContentLength int64              `json:"content_length,omitempty"`
+	Headers       map[string]string  `json:"headers,omitempty"`
+	Redirects     []couchdb.Redirect `json:"redirects,omitempty"`
+	FinalURL      string             `json:"final_url,omitempty"`
+	RedirectedTo  string             `json:"redirected_to,omitempty"`
+	//Truncated is set when the page was longer than the max body size
+	Truncated bool `json:"truncated,omitempty"`
+	//Charset is the encoding the page came in, HTML is always UTF-8
+	Charset string `json:"charset,omitempty"`
+	//Directives come from the X-Robots-Tag headers, the extractor adds the meta tags
+	parse.Directives
 }
 
-var gnatsdCredentials gnatsdCred
+//recordedHeaders are the response headers we keep with the page
+var recordedHeaders = []string{
+	"Cache-Control",
+	"Content-Language",
+	"Content-Type",
+	"Expires",
+	"Link",
+	"Location",
+	"Server",
+	"X-Robots-Tag",
+}
 
-type gnatsdCred struct {
-	URL string
+//skippedURL is stored in place of the page when we decide not to fetch a url,
+//so we don't try it again
+type skippedURL struct {
+	ID         string    `json:"_id"`
+	Rev        string    `json:"_rev,omitempty"`
+	URL        string    `json:"url"`
+	SkipReason string    `json:"skip_reason"`
+	SkippedOn  time.Time `json:"skipped_on"`
 }
 
-func fetchHTML(url string) {
-	log.V(2).Infof("Fetching %s\n", url)
+//redeliverBatch is the most urls we send back to the fetch queue on each pass
+const redeliverBatch = 100
 
-	nc, err := nats.Connect(gnatsdCredentials.URL)
-	if err != nil {
-		log.Fatalf("Could not connect to gnatsd, got: %s\n", err)
-	}
+//dispatchWait is how long we wait for new urls, or for a host to be free,
+//when none of the urls we hold can be fetched right away
+const dispatchWait = 100 * time.Millisecond
+
+//httpClient is shared by all workers, so connections to the same host are reused
+var httpClient *http.Client
+var robotsCache *robots.Cache
+
+//urlFrontier keeps the urls we get until we are done with them
+var urlFrontier *frontier.Frontier
+
+var configLoader = config.Flags(flag.CommandLine)
+var cfg *config.Config
+var db store.Store
+
+//fetchHTML gets the page and stores it. previous is the page we stored the last time
+//we fetched this url, nil if we never did. When we have it we send a conditional GET,
+//and only send the page to the extract queue if it changed.
+//It returns false when the url went back to the queue to be tried again
+func fetchHTML(nc *nats.Conn, msg queue.Message, previous *couchdb.CouchDoc) bool {
+	url := msg.URL
+	log.V(2).Infof("Fetching %s\n", url)
 
 	//Fetch url
-	client := &http.Client{}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		log.Errorf("Error parsing url: %s, got: %v\n", url, err)
+		return retryFetch(nc, msg, err.Error(), false, 0)
 	}
-	req.Header.Set("User-Agent", "OwlCrawler - https://github.com/fmpwizard/owlcrawler")
-	resp, err := client.Do(req)
+	req.Header.Set("User-Agent", userAgent)
+	if previous != nil {
+		if previous.ETag != "" {
+			req.Header.Set("If-None-Match", previous.ETag)
+		}
+		if previous.LastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.LastModified)
+		}
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		log.Errorf("Error while fetching url: %s, got error: %v\n", url, err)
-		return
+		return retryFetch(nc, msg, err.Error(), retry.TransientError(err), 0)
 	}
 
 	defer resp.Body.Close()
-	htmlData, err := ioutil.ReadAll(resp.Body)
+	if retry.TransientStatus(resp.StatusCode) {
+		wait, _ := retry.RetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return retryFetch(nc, msg, fmt.Sprintf("Got status %d", resp.StatusCode), true, wait)
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		if previous == nil {
+			//We didn't send any validators, so there is nothing it could be the same as
+			return retryFetch(nc, msg, "Got status 304 for a request that wasn't conditional", true, 0)
+		}
+		markUnchanged(previous, msg, resp)
+		return true
+	}
+	indexable := resp.StatusCode >= 200 && resp.StatusCode < 300 || cfg.Fetcher.IndexErrors
+	//We look at the headers before reading the body, so we don't download what we won't keep
+	contentType := resp.Header.Get("Content-Type")
+	if indexable && contentType != "" && !cfg.Fetcher.Content.Accepts(contentType) {
+		reason := "content type " + content.MediaType(contentType)
+		log.V(2).Infof("Skipping %s, %s\n", url, reason)
+		recordSkippedURL(url, reason)
+		return true
+	}
+	htmlData, truncated, err := readBody(resp.Body, cfg.Fetcher.MaxBodySize)
 	if err != nil {
 		log.Errorf("Error while reading html for url: %s, got error: %v\n", url, err)
-		return
+		return retryFetch(nc, msg, err.Error(), retry.TransientError(err), 0)
+	}
+	if contentType == "" {
+		contentType = content.Sniff(htmlData)
+		if indexable && !cfg.Fetcher.Content.Accepts(contentType) {
+			reason := "content type " + content.MediaType(contentType)
+			log.V(2).Infof("Skipping %s, %s\n", url, reason)
+			recordSkippedURL(url, reason)
+			return true
+		}
+	}
+	if truncated {
+		log.V(2).Infof("Truncated %s to %d bytes\n", url, len(htmlData))
+	}
+	hash := fmt.Sprintf("%x", sha1.Sum(htmlData))
+	//The hash is on the raw bytes, so a change in how we transcode doesn't look like a change in the page
+	var encoding string
+	if content.IsText(contentType) {
+		htmlData, encoding, err = parse.ToUTF8(htmlData, contentType)
+		if err != nil {
+			log.Errorf("Error transcoding %s from %s, got: %v\n", url, encoding, err)
+		}
+	}
+	if previous != nil && previous.ContentHash == hash && previous.StatusCode == resp.StatusCode {
+		markUnchanged(previous, msg, resp)
+		return true
 	}
 
 	data := &dataStore{
-		ID:        base64.URLEncoding.EncodeToString([]byte(url)),
+		ID:        couchdb.DocID(url),
 		URL:       url,
 		HTML:      string(htmlData[:]),
 		FetchedOn: time.Now().UTC(),
+		Depth:     msg.Depth,
+		Site:      msg.Site,
+		Referrer:  msg.Referrer,
+
+		StatusCode:    resp.StatusCode,
+		ETag:          resp.Header.Get("ETag"),
+		LastModified:  resp.Header.Get("Last-Modified"),
+		ContentHash:   hash,
+		Revisit:       &recrawl.History{},
+		ContentType:   contentType,
+		ContentLength: resp.ContentLength,
+		Truncated:     truncated,
+		Charset:       encoding,
+		Headers:       responseHeaders(resp),
+		Redirects:     redirectChain(resp),
+		Directives:    parse.HeaderRobots(resp.Header["X-Robots-Tag"], robotsAgent),
+	}
+	if !indexable {
+		log.V(2).Infof("Not indexing %s, got status %d\n", url, resp.StatusCode)
+		data.HTML = ""
+	}
+	//Only the types the extractor knows about go to the extract queue, the rest are just stored
+	extractable := indexable && cfg.Fetcher.Content.Extracts(contentType)
+	if len(data.Redirects) > 0 {
+		if data.FinalURL, err = parse.NormalizeURL(resp.Request.URL.String()); err != nil {
+			data.FinalURL = resp.Request.URL.String()
+		}
 	}
 
+	if previous != nil {
+		data.Rev = previous.Rev
+		if previous.Revisit != nil {
+			data.Revisit = previous.Revisit
+		}
+	}
+	data.Revisit.Record(data.FetchedOn, true, revisitPolicy(msg))
+
+	if data.FinalURL != "" && data.FinalURL != url && cfg.Fetcher.CanonicalRedirects {
+		saveRedirect(*data, previous)
+		if !db.ShouldURLBeFetched(data.FinalURL) {
+			log.V(2).Infof("Already have %s, where %s redirects to\n", data.FinalURL, url)
+			return true
+		}
+		//The page is stored under the url it redirected to, as a page we never fetched
+		previous = nil
+		data.ID = couchdb.DocID(data.FinalURL)
+		data.URL = data.FinalURL
+		data.FinalURL = ""
+		data.Rev = ""
+		data.Revisit = &recrawl.History{}
+		data.Revisit.Record(data.FetchedOn, true, revisitPolicy(msg))
+	}
 	pageData, err := json.Marshal(data)
 	if err != nil {
 		log.Errorf("Error generating json to save in database, got: %v\n", err)
 	}
 
-	ret, err := couchdb.AddURLData(url, pageData, false)
+	var ret couchdb.CouchDocCreated
+	if previous != nil {
+		ret, err = db.UpdateURLData(previous.ID, pageData)
+	} else {
+		ret, err = db.AddURLData(data.URL, pageData, false)
+	}
 	if err == nil {
+		//So extractors don't queue it again
+		if err := seen.Announce(nc, data.URL); err != nil {
+			log.Errorf("Failed to announce %s, got: %v\n", data.URL, err)
+		}
+	}
+	if err == nil && msg.Depth == 0 {
+		discoverSitemaps(msg)
+	}
+	if err == nil && extractable {
 		//Send fethed url to parse queue
-		err := nc.Publish(extractQueue, []byte(ret.ID))
+		extract := msg
+		extract.URL = data.URL
+		extract.DocID = ret.ID
+		payload, err := queue.Encode(extract)
+		if err == nil {
+			err = nc.Publish(extractQueue, payload)
+		}
 		if err != nil {
 			log.Errorf("Failed to push %s to extract queue\n", url)
 		}
 	}
 	log.V(2).Infof("Finished getting %s", url)
+	return true
 }
 
-func main() {
+//discoverSitemaps queues the sitemaps of the site we just fetched the home page of,
+//the ones its robots.txt lists or /sitemap.xml
+func discoverSitemaps(msg queue.Message) {
+	var listed []string
+	if target, err := neturl.Parse(msg.URL); err == nil {
+		if rules, err := robotsCache.Get(target); err == nil {
+			listed = rules.Sitemaps
+		}
+	}
+	for _, loc := range sitemap.Locations(msg.URL, listed) {
+		queueSitemap(msg, loc)
+	}
+}
 
-	log.V(1).Infof("Starting Fetcher.")
-	nc, _ := nats.Connect(gnatsdCredentials.URL)
-	sub, err := nc.QueueSubscribeSync(fetchQueue, "fetch-pool")
+func queueSitemap(from queue.Message, loc string) {
+	normalized, err := parse.NormalizeURL(loc)
 	if err != nil {
-		log.Fatalf("Error while subscribing to fetch_url, got %s\n", err)
+		log.Errorf("Invalid sitemap url %s, got: %v\n", loc, err)
+		return
 	}
-	for {
-		if payload, err := sub.NextMsg(30 * time.Second); err == nil {
-			if couchdb.ShouldURLBeFetched(string(payload.Data[:])) {
-				//TODO implement a distributed tick, so you can have 100 fetchers
-				//and you don't all go at the same time, in 5 sec intervals
-				<-time.Tick(5 * time.Second)
-				fetchHTML(string(payload.Data[:]))
+	msg := queue.Message{
+		URL:      normalized,
+		Referrer: from.URL,
+		Site:     from.Site,
+		Priority: queue.PriorityHigh,
+		Kind:     queue.KindSitemap,
+	}
+	if err := urlFrontier.Push(msg); err != nil {
+		log.Errorf("Failed to push sitemap %s to fetch queue, got: %v\n", loc, err)
+	}
+}
+
+//fetchSitemap queues the pages a sitemap lists, with the priority and change hints it gives them,
+//and the sitemaps a sitemap index lists. It returns false when the sitemap went back to the queue
+func fetchSitemap(nc *nats.Conn, msg queue.Message) bool {
+	log.V(2).Infof("Fetching sitemap %s\n", msg.URL)
+	req, err := http.NewRequest("GET", msg.URL, nil)
+	if err != nil {
+		return retryFetch(nc, msg, err.Error(), false, 0)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return retryFetch(nc, msg, err.Error(), retry.TransientError(err), 0)
+	}
+	defer resp.Body.Close()
+	if retry.TransientStatus(resp.StatusCode) {
+		wait, _ := retry.RetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return retryFetch(nc, msg, fmt.Sprintf("Got status %d", resp.StatusCode), true, wait)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.V(2).Infof("No sitemap at %s, got status %d\n", msg.URL, resp.StatusCode)
+		return true
+	}
+	body, truncated, err := readBody(resp.Body, sitemap.MaxSize)
+	if err != nil {
+		return retryFetch(nc, msg, err.Error(), retry.TransientError(err), 0)
+	}
+	if truncated {
+		log.Errorf("Sitemap %s is larger than %d bytes, reading what fits\n", msg.URL, sitemap.MaxSize)
+	}
+	parsed, err := sitemap.Parse(body)
+	if err != nil {
+		log.Errorf("Invalid sitemap %s, got: %v\n", msg.URL, err)
+		return true
+	}
+	for _, loc := range parsed.Sitemaps {
+		queueSitemap(msg, loc)
+	}
+	siteScope, err := store.SiteScope(db, msg.Site, msg.URL)
+	if err != nil {
+		log.Errorf("%v\n", err)
+	}
+	queued := 0
+	for _, entry := range parsed.URLs {
+		page, err := sitemapPage(msg, entry)
+		if err != nil {
+			log.V(3).Infof("Skipping %s from sitemap %s, got: %v\n", entry.Loc, msg.URL, err)
+			continue
+		}
+		if ok, reason := siteScope.Allows(page.URL, page.Depth); !ok {
+			log.V(3).Infof("Not fetching %s, %s\n", page.URL, reason)
+			continue
+		}
+		if err := urlFrontier.Push(page); err != nil {
+			log.Errorf("Failed to push %s to fetch queue, got: %v\n", page.URL, err)
+			continue
+		}
+		queued++
+	}
+	log.V(2).Infof("Queued %d urls and %d sitemaps from %s\n", queued, len(parsed.Sitemaps), msg.URL)
+	return true
+}
+
+//sitemapPage is the message for a page listed in a sitemap. They count as
+//links from the home page. Pages we already have are fetched again if the
+//sitemap says they changed since
+func sitemapPage(from queue.Message, entry sitemap.URL) (queue.Message, error) {
+	loc, err := parse.NormalizeURL(entry.Loc)
+	if err != nil {
+		return queue.Message{}, err
+	}
+	page := queue.Message{
+		URL:        loc,
+		Referrer:   from.URL,
+		Depth:      1,
+		Site:       from.Site,
+		Priority:   queue.PriorityNormal,
+		ChangeFreq: entry.ChangeFreq,
+	}
+	if entry.Priority != nil {
+		page.Priority = queue.SitemapPriority(*entry.Priority)
+	}
+	if modified := entry.Modified(); !modified.IsZero() {
+		page.LastMod = &modified
+		page.Recrawl = true
+	}
+	return page, nil
+}
+
+//fetchFeed queues the entries of a feed, ahead of the links we find, and records the feed
+//so the recrawler sends it again after the feed interval. Entries that didn't change
+//since we last read it are left out. It returns false when the feed went back to the queue
+func fetchFeed(nc *nats.Conn, msg queue.Message) bool {
+	log.V(2).Infof("Fetching feed %s\n", msg.URL)
+	known, err := db.GetFeed(couchdb.FeedID(msg.URL))
+	isNew := err != nil
+	if isNew {
+		known = couchdb.Feed{URL: msg.URL, Site: msg.Site}
+	}
+	req, err := http.NewRequest("GET", msg.URL, nil)
+	if err != nil {
+		return retryFetch(nc, msg, err.Error(), false, 0)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if known.ETag != "" {
+		req.Header.Set("If-None-Match", known.ETag)
+	}
+	if known.LastModified != "" {
+		req.Header.Set("If-Modified-Since", known.LastModified)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return retryFetch(nc, msg, err.Error(), retry.TransientError(err), 0)
+	}
+	defer resp.Body.Close()
+	if retry.TransientStatus(resp.StatusCode) {
+		wait, _ := retry.RetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return retryFetch(nc, msg, fmt.Sprintf("Got status %d", resp.StatusCode), true, wait)
+	}
+	lastPoll := known.PolledOn
+	known.PolledOn = time.Now().UTC()
+	known.NextPoll = known.PolledOn.Add(cfg.Feeds.Interval.Duration)
+	if resp.StatusCode == http.StatusNotModified {
+		saveFeed(known)
+		return true
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.V(2).Infof("No feed at %s, got status %d\n", msg.URL, resp.StatusCode)
+		if !isNew {
+			saveFeed(known)
+		}
+		return true
+	}
+	body, _, err := readBody(resp.Body, cfg.Fetcher.MaxBodySize)
+	if err != nil {
+		return retryFetch(nc, msg, err.Error(), retry.TransientError(err), 0)
+	}
+	parsed, err := feed.Parse(body, msg.URL)
+	if err != nil {
+		log.Errorf("Invalid feed %s, got: %v\n", msg.URL, err)
+		return true
+	}
+	siteScope, err := store.SiteScope(db, known.Site, msg.URL)
+	if err != nil {
+		log.Errorf("%v\n", err)
+	}
+	queued := 0
+	for _, entry := range parsed.Entries {
+		if !entry.Updated.IsZero() && entry.Updated.Before(lastPoll) {
+			continue
+		}
+		page, err := feedPage(msg, known.Site, entry)
+		if err != nil {
+			log.V(3).Infof("Skipping %s from feed %s, got: %v\n", entry.URL, msg.URL, err)
+			continue
+		}
+		if ok, reason := siteScope.Allows(page.URL, page.Depth); !ok {
+			log.V(3).Infof("Not fetching %s, %s\n", page.URL, reason)
+			continue
+		}
+		if err := urlFrontier.Push(page); err != nil {
+			log.Errorf("Failed to push %s to fetch queue, got: %v\n", page.URL, err)
+			continue
+		}
+		queued++
+	}
+	log.V(2).Infof("Queued %d of %d entries from %s\n", queued, len(parsed.Entries), msg.URL)
+	known.Title = parsed.Title
+	known.Entries = len(parsed.Entries)
+	known.ETag = resp.Header.Get("ETag")
+	known.LastModified = resp.Header.Get("Last-Modified")
+	saveFeed(known)
+	return true
+}
+
+//feedPage is the message for an entry of a feed. Entries count as links from the
+//home page. Pages we already have are fetched again if the feed says they changed since
+func feedPage(from queue.Message, site string, entry feed.Entry) (queue.Message, error) {
+	loc, err := parse.NormalizeURL(entry.URL)
+	if err != nil {
+		return queue.Message{}, err
+	}
+	page := queue.Message{
+		URL:      loc,
+		Referrer: from.URL,
+		Depth:    1,
+		Site:     site,
+		Priority: queue.PriorityHigh,
+	}
+	if !entry.Updated.IsZero() {
+		updated := entry.Updated
+		page.LastMod = &updated
+		page.Recrawl = true
+	}
+	return page, nil
+}
+
+func saveFeed(known couchdb.Feed) {
+	if err := db.SaveFeed(known); err != nil {
+		log.Errorf("Error recording feed %s, got: %v\n", known.URL, err)
+	}
+}
+
+//checkHead asks for the headers of url, telling us not to fetch it
+//when its content type is one we don't keep. If the HEAD request fails we
+//let the GET decide
+func checkHead(client *http.Client, url string) (bool, string) {
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		return true, ""
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, ""
+	}
+	resp.Body.Close()
+	contentType := resp.Header.Get("Content-Type")
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 && contentType != "" && !cfg.Fetcher.Content.Accepts(contentType) {
+		return false, "content type " + content.MediaType(contentType)
+	}
+	return true, ""
+}
+
+//readBody reads up to max bytes of body, telling us if there was more
+func readBody(body io.Reader, max int64) ([]byte, bool, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, max+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(data)) > max {
+		return data[:max], true, nil
+	}
+	return data, false, nil
+}
+
+//retryFetch sends msg back to the fetch queue once it's time to try again,
+//waiting at least retryAfter. Urls that failed for good, or too many times,
+//go to the dead letter queue and the database instead. It returns true when we gave up
+func retryFetch(nc *nats.Conn, msg queue.Message, reason string, transient bool, retryAfter time.Duration) bool {
+	policy := cfg.Fetcher.RetryPolicy()
+	msg.Retries++
+	if !transient || policy.Exhausted(msg.Retries) {
+		deadLetter(nc, msg, reason)
+		return true
+	}
+	wait := policy.Backoff(msg.Retries)
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	log.V(2).Infof("Trying %s again in %v, %s\n", msg.URL, wait, reason)
+	requeueAfter(nc, msg, wait)
+	return false
+}
+
+//deferred are the urls waiting to go back to the fetch queue,
+//so we can send them right away when we shut down
+var deferred = struct {
+	sync.Mutex
+	timers map[*time.Timer]queue.Message
+}{timers: make(map[*time.Timer]queue.Message)}
+
+//requeueAfter sends msg back to the fetch queue once wait is over.
+//The frontier keeps it in the meantime, in case we go away before that
+func requeueAfter(nc *nats.Conn, msg queue.Message, wait time.Duration) {
+	if err := urlFrontier.Defer(msg, wait); err != nil {
+		log.Errorf("Error deferring %s in the frontier, got: %v\n", msg.URL, err)
+	}
+	deferred.Lock()
+	defer deferred.Unlock()
+	var timer *time.Timer
+	timer = time.AfterFunc(wait, func() {
+		deferred.Lock()
+		delete(deferred.timers, timer)
+		deferred.Unlock()
+		requeue(nc, msg)
+	})
+	deferred.timers[timer] = msg
+}
+
+//requeueDeferred sends the urls waiting in requeueAfter back to the fetch queue now
+func requeueDeferred(nc *nats.Conn) {
+	deferred.Lock()
+	defer deferred.Unlock()
+	for timer, msg := range deferred.timers {
+		if timer.Stop() {
+			republish(nc, msg)
+		}
+		delete(deferred.timers, timer)
+	}
+}
+
+//requeue sends msg back to the fetch queue, on the lane of its priority
+func requeue(nc *nats.Conn, msg queue.Message) {
+	payload, err := queue.Encode(msg)
+	if err == nil {
+		err = nc.Publish(frontier.SubjectFor(msg), payload)
+	}
+	if err != nil {
+		log.Errorf("Failed to push %s back to fetch queue\n", msg.URL)
+	}
+}
+
+//republish sends msg back to the fetch queue now, giving its frontier entry a new
+//ack timeout so the frontier doesn't publish it a second time
+func republish(nc *nats.Conn, msg queue.Message) {
+	if err := urlFrontier.Defer(msg, 0); err != nil {
+		log.Errorf("Error deferring %s in the frontier, got: %v\n", msg.URL, err)
+	}
+	requeue(nc, msg)
+}
+
+//deadLetter gives up on msg, publishing it to the dead letter queue and
+//recording it so it can be requeued from the webapp
+func deadLetter(nc *nats.Conn, msg queue.Message, reason string) {
+	log.Errorf("Giving up on %s after %d attempts, %s\n", msg.URL, msg.Retries, reason)
+	letter := couchdb.DeadLetter{
+		URL:      msg.URL,
+		Reason:   reason,
+		Attempts: msg.Retries,
+		FailedOn: time.Now().UTC(),
+		Message:  msg,
+	}
+	if payload, err := json.Marshal(letter); err == nil {
+		nc.Publish(deadLetterQueue, payload)
+	}
+	if err := db.AddDeadLetter(letter); err != nil {
+		log.Errorf("Error recording dead letter for %s, got: %v\n", msg.URL, err)
+	}
+}
+
+//saveRedirect stores a document for the url we asked for, pointing to the
+//page it redirected to
+func saveRedirect(data dataStore, previous *couchdb.CouchDoc) {
+	data.HTML = ""
+	data.ContentHash = ""
+	data.RedirectedTo = data.FinalURL
+	data.StatusCode = data.Redirects[0].Status
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Errorf("Error generating json to save in database, got: %v\n", err)
+		return
+	}
+	if previous != nil {
+		_, err = db.UpdateURLData(previous.ID, payload)
+	} else {
+		_, err = db.AddURLData(data.URL, payload, false)
+	}
+	if err != nil {
+		log.Errorf("Error recording redirect of %s, got: %v\n", data.URL, err)
+	}
+}
+
+//redirectChain lists the redirects we followed to get resp, oldest first
+func redirectChain(resp *http.Response) []couchdb.Redirect {
+	var chain []couchdb.Redirect
+	for r := resp.Request.Response; r != nil; r = r.Request.Response {
+		chain = append([]couchdb.Redirect{{URL: r.Request.URL.String(), Status: r.StatusCode}}, chain...)
+	}
+	return chain
+}
+
+//responseHeaders picks the recordedHeaders out of resp
+func responseHeaders(resp *http.Response) map[string]string {
+	headers := make(map[string]string)
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+//markUnchanged records that we fetched doc again and it didn't change
+func markUnchanged(doc *couchdb.CouchDoc, msg queue.Message, resp *http.Response) {
+	log.V(2).Infof("%s did not change\n", doc.URL)
+	doc.FetchedOn = time.Now().UTC()
+	if doc.Revisit == nil {
+		//Pages fetched before we kept a history start from here
+		doc.Revisit = &recrawl.History{}
+	}
+	doc.Revisit.Record(doc.FetchedOn, false, revisitPolicy(msg))
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		doc.ETag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		doc.LastModified = lastModified
+	}
+	//The page isn't extracted again, so this is where it learns about links stored since
+	if anchors, err := store.AnchorTexts(db, doc.URL); err == nil {
+		doc.Anchors = anchors
+	} else {
+		log.Errorf("Error getting the links to %s, got: %v\n", doc.URL, err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		log.Errorf("Error generating json to save in database, got: %v\n", err)
+		return
+	}
+	if _, err := db.UpdateURLData(doc.ID, data); err != nil {
+		log.Errorf("Error updating %s, got: %v\n", doc.URL, err)
+	}
+}
+
+//recrawlCandidate gets the page we stored for the url in msg, if it's old enough to be fetched again.
+//Pages with a revisit history are due on their next visit, the rest after RecrawlAfter.
+//Urls someone asked to crawl now, and pages a sitemap says changed since we fetched them, skip that check
+func recrawlCandidate(msg queue.Message) (*couchdb.CouchDoc, bool) {
+	url := msg.URL
+	doc, err := db.GetURLData(couchdb.DocID(url))
+	if err != nil || doc.FetchedOn.IsZero() {
+		return nil, false
+	}
+	if msg.Priority >= queue.PriorityNow || (msg.LastMod != nil && msg.LastMod.After(doc.FetchedOn)) {
+		return &doc, true
+	}
+	due := doc.FetchedOn.Add(cfg.Fetcher.RecrawlAfter.Duration)
+	if doc.Revisit != nil && !doc.Revisit.NextVisit.IsZero() {
+		due = doc.Revisit.NextVisit
+	}
+	if time.Now().Before(due) {
+		log.V(3).Infof("Not recrawling %s, fetched on %v\n", url, doc.FetchedOn)
+		return nil, false
+	}
+	return &doc, true
+}
+
+//revisitPolicy is the recrawl policy for the page in msg. New pages start
+//with the change frequency their sitemap gives, if any
+func revisitPolicy(msg queue.Message) recrawl.Policy {
+	policy := cfg.Recrawl.Policy()
+	if interval := sitemap.ChangeInterval(msg.ChangeFreq); interval > 0 {
+		policy.Initial = interval
+	}
+	return policy
+}
+
+//siteIntervalTTL is how long we keep the crawl delay of a site before reading it again
+const siteIntervalTTL = 5 * time.Minute
+
+//siteIntervals caches the crawl delay of the site each host belongs to
+var siteIntervals = politeness.NewIntervals(siteIntervalTTL)
+
+//hostInterval is the minimum time between two requests to the host of url.
+//A per-site override replaces the default, but we never go faster than robots.txt asks
+func hostInterval(msg queue.Message, crawlDelay time.Duration) time.Duration {
+	interval := siteIntervals.Get(urlHost(msg.URL), func() time.Duration {
+		if site, err := store.FindSite(db, msg.Site, msg.URL); err == nil && site.CrawlDelay > 0 {
+			return time.Duration(site.CrawlDelay) * time.Second
+		}
+		return cfg.Fetcher.CrawlDelay.Duration
+	})
+	if crawlDelay > interval {
+		interval = crawlDelay
+	}
+	return interval
+}
+
+//checkRobots tells us if we can fetch url, and the Crawl-delay its robots.txt asks for.
+//Disallowed urls are recorded in the database. It gives robots.ErrUnavailable when
+//we could not get the robots.txt
+func checkRobots(msg queue.Message) (bool, time.Duration, error) {
+	url := msg.URL
+	allowed, reason, err := robotsCache.Check(url)
+	if err == robots.ErrUnavailable {
+		log.V(2).Infof("Deferring %s, %s\n", url, reason)
+		return false, 0, err
+	}
+	if !allowed {
+		log.V(2).Infof("Skipping %s, %s\n", url, reason)
+		recordSkippedURL(url, reason)
+		return false, 0, nil
+	}
+	target, _ := neturl.Parse(url)
+	rules, _ := robotsCache.Get(target)
+	return true, rules.CrawlDelay, nil
+}
+
+//recordSkippedURL saves why we didn't fetch url. A page we stored before, like one
+//we recrawl, is replaced by the record, so we don't keep serving what we now skip
+func recordSkippedURL(url, reason string) {
+	data := &skippedURL{
+		ID:         couchdb.DocID(url),
+		URL:        url,
+		SkipReason: reason,
+		SkippedOn:  time.Now().UTC(),
+	}
+	stored := !db.ShouldURLBeFetched(url)
+	if stored {
+		doc, err := db.GetURLData(data.ID)
+		if err != nil {
+			log.Errorf("Error getting %s to record it was skipped, got: %v\n", url, err)
+			return
+		}
+		data.Rev = doc.Rev
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Errorf("Error generating json to save in database, got: %v\n", err)
+		return
+	}
+	if stored {
+		_, err = db.UpdateURLData(data.ID, payload)
+	} else {
+		_, err = db.AddURLData(url, payload, false)
+	}
+	if err != nil {
+		log.Errorf("Error recording skipped url %s, got: %v\n", url, err)
+	}
+}
+
+//newHTTPClient is the client all workers share. It keeps a few idle connections
+//per host around, and gives up on hosts that are too slow to answer
+func newHTTPClient(f config.Fetcher) *http.Client {
+	return &http.Client{
+		Timeout: f.Timeout.Duration,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   f.PerHost,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: f.Timeout.Duration,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+//inFlight are the urls the workers are on, so we can requeue them
+//if they don't finish before we shut down
+var inFlight = struct {
+	sync.Mutex
+	next int
+	msgs map[int]queue.Message
+}{msgs: make(map[int]queue.Message)}
+
+//worker fetches the urls it gets from jobs, until jobs is closed.
+//The dispatcher took a slot of limiter for each of them, the worker gives it back
+func worker(nc *nats.Conn, scheduler *politeness.Scheduler, limiter *politeness.Limiter, jobs <-chan queue.Message) {
+	for msg := range jobs {
+		inFlight.Lock()
+		id := inFlight.next
+		inFlight.next++
+		inFlight.msgs[id] = msg
+		inFlight.Unlock()
+
+		if process(nc, scheduler, msg) {
+			if err := urlFrontier.Ack(msg); err != nil {
+				log.Errorf("Error acknowledging %s, got: %v\n", msg.URL, err)
 			}
 		}
+		limiter.Release(urlHost(msg.URL))
+
+		inFlight.Lock()
+		delete(inFlight.msgs, id)
+		inFlight.Unlock()
 	}
 }
 
-func init() {
-	flag.Parse()
-	if u, err := user.Current(); err == nil {
-		path := filepath.Join(u.HomeDir, ".gnatsd.json")
-		content, err := ioutil.ReadFile(path)
+//redeliver sends the urls nobody was done with in time back to the fetch queue, until stop is closed
+func redeliver(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(cfg.Frontier.RedeliverEvery.Duration):
+		}
+		sent, err := urlFrontier.Redeliver(time.Now().UTC(), redeliverBatch)
 		if err != nil {
-			log.Fatalf("Error reading gnatds user file, got: %v\n", err)
+			log.Errorf("Error redelivering urls, got: %v\n", err)
 		}
+		if sent > 0 {
+			log.V(2).Infof("Redelivered %d urls\n", sent)
+		}
+	}
+}
+
+//dispatcher takes the urls from every lane of the fetch queue and hands them to
+//the workers, always the highest ranked one whose host we can hit right now
+type dispatcher struct {
+	nc        *nats.Conn
+	scheduler *politeness.Scheduler
+	limiter   *politeness.Limiter
+	subs      []*nats.Subscription
+	//lanes get the urls of each subscription, in the order of frontier.Lanes
+	lanes   []chan queue.Message
+	arrived chan struct{}
+	//stopping is closed when we shut down, urls that arrive after that go back to the queue
+	stopping chan struct{}
+	pending  frontier.Pending
+	//later is when the last url we put off of each host goes back to the queue
+	mu    sync.Mutex
+	later map[string]time.Time
+	//overflow has the urls we have no room for, putOffLoop sends them back to the queue
+	overflow chan queue.Message
+}
 
-		err = json.Unmarshal(content, &gnatsdCredentials)
+//newDispatcher subscribes to every lane of the fetch queue
+func newDispatcher(nc *nats.Conn, scheduler *politeness.Scheduler, limiter *politeness.Limiter) (*dispatcher, error) {
+	d := &dispatcher{
+		nc:        nc,
+		scheduler: scheduler,
+		limiter:   limiter,
+		arrived:   make(chan struct{}, 1),
+		stopping:  make(chan struct{}),
+		pending:   frontier.Pending{PerHost: cfg.Fetcher.PerHost},
+		later:     make(map[string]time.Time),
+		overflow:  make(chan queue.Message, cfg.Fetcher.Buffer),
+	}
+	for _, subject := range frontier.Lanes {
+		lane := make(chan queue.Message, cfg.Fetcher.Buffer)
+		sub, err := nc.QueueSubscribe(subject, "fetch-pool", func(payload *nats.Msg) {
+			msg, err := queue.DecodeFetch(payload.Data)
+			if err != nil {
+				log.Errorf("Dropping invalid message, got: %v\n", err)
+				return
+			}
+			msg.URL, err = parse.NormalizeURL(msg.URL)
+			if err != nil {
+				log.Errorf("Dropping invalid url %s, got: %v\n", string(payload.Data[:]), err)
+				return
+			}
+			select {
+			case <-d.stopping:
+				republish(nc, msg)
+				return
+			default:
+			}
+			//Blocking here would hold up every url gnatsd has for us,
+			//we only wait when putOffLoop is behind too
+			select {
+			case lane <- msg:
+			case d.overflow <- msg:
+				return
+			case <-d.stopping:
+				republish(nc, msg)
+				return
+			}
+			select {
+			case d.arrived <- struct{}{}:
+			default:
+			}
+		})
 		if err != nil {
-			log.Fatalf("Invalid gnatsd credentials file, got: %v\n", err)
+			return nil, fmt.Errorf("Error while subscribing to %s, got %v", subject, err)
+		}
+		d.subs = append(d.subs, sub)
+		d.lanes = append(d.lanes, lane)
+	}
+	go d.putOffLoop()
+	return d, nil
+}
+
+//fill moves the urls waiting on the lanes to pending, higher lanes first.
+//Urls pending has no room for, because their host has enough waiting or
+//pending is full of higher ranked ones, are put off
+func (d *dispatcher) fill() {
+	for _, lane := range d.lanes {
+		//We are the only ones reading from the lanes, so this never blocks
+		for len(lane) > 0 {
+			if overflow, full := d.pending.Push(<-lane); full {
+				d.overflow <- overflow
+			}
+			if d.pending.Len() > cfg.Fetcher.Buffer {
+				lowest, _ := d.pending.PopLowest()
+				d.overflow <- lowest
+			}
+		}
+	}
+	d.mu.Lock()
+	now := time.Now()
+	for host, at := range d.later {
+		if at.Before(now) {
+			delete(d.later, host)
+		}
+	}
+	d.mu.Unlock()
+}
+
+//putOffLoop puts off the urls on overflow until we shut down. Putting off talks to
+//the database, so it runs here instead of on the gnatsd callbacks or in run
+func (d *dispatcher) putOffLoop() {
+	for {
+		select {
+		case msg := <-d.overflow:
+			d.putOff(msg)
+		case <-d.stopping:
+			return
 		}
 	}
 }
+
+//putOff sends msg back to the queue for when its host could take it. The urls
+//we put off of the same host go back one crawl delay apart, so they don't keep
+//coming back before their turn
+func (d *dispatcher) putOff(msg queue.Message) {
+	host := urlHost(msg.URL)
+	interval := hostInterval(msg, 0)
+	d.mu.Lock()
+	at := time.Now()
+	if d.later[host].After(at) {
+		at = d.later[host]
+	}
+	at = at.Add(interval)
+	d.later[host] = at
+	d.mu.Unlock()
+	log.V(3).Infof("No room for %s, putting it off for %v\n", msg.URL, at.Sub(time.Now()))
+	requeueAfter(d.nc, msg, at.Sub(time.Now()))
+}
+
+//eligible tells us if a worker could start on msg right now
+func (d *dispatcher) eligible(msg queue.Message) bool {
+	host := urlHost(msg.URL)
+	return d.limiter.Free(host) && d.scheduler.Ready(host)
+}
+
+//run hands urls to jobs until stop gets a signal
+func (d *dispatcher) run(jobs chan<- queue.Message, stop <-chan os.Signal) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		d.fill()
+		msg, ok := d.pending.Pop(d.eligible)
+		if !ok {
+			select {
+			case <-stop:
+				return
+			case <-d.arrived:
+			case <-time.After(dispatchWait):
+			}
+			continue
+		}
+		host := urlHost(msg.URL)
+		//We are the only ones taking slots, the one eligible saw is still free
+		d.limiter.TryAcquire(host)
+		select {
+		case jobs <- msg:
+		case <-stop:
+			d.limiter.Release(host)
+			if overflow, full := d.pending.Push(msg); full {
+				d.overflow <- overflow
+			}
+			return
+		}
+	}
+}
+
+//requeueAll stops taking urls and sends the ones we hold back to the queue, for other fetchers
+func (d *dispatcher) requeueAll() {
+	close(d.stopping)
+	for i, sub := range d.subs {
+		if err := sub.Unsubscribe(); err != nil {
+			log.Errorf("Error unsubscribing from %s, got: %v\n", frontier.Lanes[i], err)
+		}
+	}
+	for _, lane := range d.lanes {
+		for len(lane) > 0 {
+			republish(d.nc, <-lane)
+		}
+	}
+	//putOffLoop may still take one, so we don't wait on it
+	for drained := false; !drained; {
+		select {
+		case msg := <-d.overflow:
+			republish(d.nc, msg)
+		default:
+			drained = true
+		}
+	}
+	for _, msg := range d.pending.Drain() {
+		republish(d.nc, msg)
+	}
+}
+
+//urlHost is the host we fetch url from
+func urlHost(url string) string {
+	target, err := neturl.Parse(url)
+	if err != nil {
+		return ""
+	}
+	return target.Host
+}
+
+//shutdown stops taking urls from the fetch queue and gives the workers until
+//the shutdown timeout to finish. Whatever they are still on, the urls the
+//dispatcher holds and the urls waiting to be retried go back to the queue
+//for other fetchers. They stay in the frontier until someone is done with them
+func shutdown(nc *nats.Conn, d *dispatcher, jobs chan queue.Message, workers *sync.WaitGroup, stopRedeliver chan struct{}) {
+	log.Infoln("Shutting down, waiting for in-flight urls")
+	close(stopRedeliver)
+	d.requeueAll()
+	close(jobs)
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(cfg.ShutdownTimeout.Duration):
+		inFlight.Lock()
+		for _, msg := range inFlight.msgs {
+			log.Infof("Requeueing %s, it didn't finish in time\n", msg.URL)
+			republish(nc, msg)
+		}
+		inFlight.Unlock()
+	}
+	requeueDeferred(nc)
+	if err := nc.Flush(); err != nil {
+		log.Errorf("Error flushing messages to gnatsd, got: %v\n", err)
+	}
+	nc.Close()
+	log.Infoln("Fetcher stopped")
+}
+
+//process decides if we fetch the url in msg, and fetches it once it's our turn to hit its host.
+//It returns false when the url went back to the queue, true when we are done with it
+func process(nc *nats.Conn, scheduler *politeness.Scheduler, msg queue.Message) bool {
+	var previous *couchdb.CouchDoc
+	//Sitemaps and feeds are not stored as pages, we read them every time
+	if msg.Kind == "" && !db.ShouldURLBeFetched(msg.URL) {
+		var ok bool
+		if !msg.Recrawl {
+			return true
+		}
+		if previous, ok = recrawlCandidate(msg); !ok {
+			return true
+		}
+	}
+	allowed, crawlDelay, err := checkRobots(msg)
+	if err == robots.ErrUnavailable {
+		requeueAfter(nc, msg, robotsRetryDelay)
+		return false
+	}
+	if !allowed {
+		return true
+	}
+	host, interval := urlHost(msg.URL), hostInterval(msg, crawlDelay)
+	scheduler.Wait(host, interval)
+	switch msg.Kind {
+	case queue.KindSitemap:
+		return fetchSitemap(nc, msg)
+	case queue.KindFeed:
+		return fetchFeed(nc, msg)
+	}
+	if cfg.Fetcher.HeadFirst && previous == nil {
+		if ok, reason := checkHead(httpClient, msg.URL); !ok {
+			log.V(2).Infof("Skipping %s, %s\n", msg.URL, reason)
+			recordSkippedURL(msg.URL, reason)
+			return true
+		}
+		//The GET is another request to the host, it needs a slot of its own
+		scheduler.Wait(host, interval)
+	}
+	return fetchHTML(nc, msg, previous)
+}
+
+func main() {
+
+	flag.Parse()
+	log.V(1).Infof("Starting Fetcher.")
+	var err error
+	cfg, err = configLoader.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration, got: %v\n", err)
+	}
+	db, err = store.Open(cfg)
+	if err != nil {
+		log.Fatalf("Could not open %s store, got: %v\n", cfg.Store, err)
+	}
+	httpClient = newHTTPClient(cfg.Fetcher)
+	robotsCache = robots.NewCache(httpClient, robotsAgent, userAgent, 24*time.Hour)
+	nc, err := nats.Connect(cfg.Gnatsd.URL)
+	if err != nil {
+		log.Fatalf("Could not connect to gnatsd, got: %v\n", err)
+	}
+	hostname, _ := os.Hostname()
+	scheduler, err := politeness.NewScheduler(nc, fmt.Sprintf("%s-%d", hostname, os.Getpid()))
+	if err != nil {
+		log.Fatalf("Error while subscribing to %s, got %s\n", politeness.Subject, err)
+	}
+	urlFrontier = frontier.New(db, nc, cfg.Frontier.AckTimeout.Duration, cfg.Frontier.MaxDeliveries)
+	stopRedeliver := make(chan struct{})
+	go redeliver(stopRedeliver)
+	limiter := politeness.NewLimiter(cfg.Fetcher.PerHost)
+	d, err := newDispatcher(nc, scheduler, limiter)
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+	jobs := make(chan queue.Message)
+	var workers sync.WaitGroup
+	for i := 0; i < cfg.Fetcher.Workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker(nc, scheduler, limiter, jobs)
+		}()
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer shutdown(nc, d, jobs, &workers, stopRedeliver)
+	d.run(jobs, stop)
+}
@@ -0,0 +1,187 @@
+package frontier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/queue"
+	log "github.com/golang/glog"
+)
+
+//Subject is where fetchers get the urls of normal priority from,
+//the other priorities have their own lane under it
+const Subject = "fetch_url"
+
+//Lanes are the subjects fetchers get urls from, highest priority first
+var Lanes = []string{Subject + ".now", Subject + ".high", Subject, Subject + ".low"}
+
+//SubjectFor is the lane msg is published on, based on its priority.
+//Fetchers order the urls of the same lane by depth themselves
+func SubjectFor(msg queue.Message) string {
+	switch {
+	case msg.Priority >= queue.PriorityNow:
+		return Lanes[0]
+	case msg.Priority >= queue.PriorityHigh:
+		return Lanes[1]
+	case msg.Priority < queue.PriorityNormal:
+		return Lanes[3]
+	}
+	return Subject
+}
+
+//Storage is where the frontier keeps its entries, every store.Store is one
+type Storage interface {
+	Enqueue(entry couchdb.FrontierEntry) (bool, error)
+	UpdateFrontier(entry couchdb.FrontierEntry) error
+	GetFrontier(id string) (couchdb.FrontierEntry, error)
+	RemoveFrontier(id string) error
+	DueFrontier(before time.Time, limit int) ([]couchdb.FrontierEntry, error)
+	AddDeadLetter(letter couchdb.DeadLetter) error
+}
+
+//Publisher sends messages to gnatsd, a *nats.Conn is one
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+//Frontier keeps every url we publish on Subject in the database until a fetcher
+//acknowledges it, publishing it again if nobody does within the ack timeout
+type Frontier struct {
+	storage    Storage
+	pub        Publisher
+	ackTimeout time.Duration
+	//maxDeliveries is how many times we publish an entry before giving up on it
+	maxDeliveries int
+}
+
+//New creates a Frontier. Urls not acknowledged within ackTimeout are published again,
+//up to maxDeliveries times
+func New(storage Storage, pub Publisher, ackTimeout time.Duration, maxDeliveries int) *Frontier {
+	return &Frontier{
+		storage:       storage,
+		pub:           pub,
+		ackTimeout:    ackTimeout,
+		maxDeliveries: maxDeliveries,
+	}
+}
+
+//Push adds msg to the frontier and publishes it. Urls already waiting in the
+//frontier are only published again when msg ranks higher than what is waiting
+func (f *Frontier) Push(msg queue.Message) error {
+	now := time.Now().UTC()
+	added, err := f.storage.Enqueue(couchdb.FrontierEntry{
+		URL:        msg.URL,
+		Site:       msg.Site,
+		QueuedOn:   now,
+		VisibleAt:  now.Add(f.ackTimeout),
+		Deliveries: 1,
+		Message:    msg,
+	})
+	if err != nil {
+		//Better to publish it without a safety net than to lose it
+		log.Errorf("Error adding %s to the frontier, got: %v\n", msg.URL, err)
+	} else if !added {
+		return f.promote(msg, now)
+	}
+	return f.publish(msg)
+}
+
+//promote publishes msg again on a higher lane, if it ranks higher than the entry waiting for its url
+func (f *Frontier) promote(msg queue.Message, now time.Time) error {
+	entry, err := f.storage.GetFrontier(couchdb.FrontierID(msg.URL))
+	if err != nil {
+		return err
+	}
+	if msg.Rank() <= entry.Message.Rank() {
+		log.V(3).Infof("%s is already waiting to be fetched\n", msg.URL)
+		return nil
+	}
+	entry.Message = msg
+	entry.VisibleAt = now.Add(f.ackTimeout)
+	entry.Deliveries++
+	if err := f.storage.UpdateFrontier(entry); err != nil {
+		return err
+	}
+	//Whichever copy a fetcher gets second finds the url fetched already and drops it
+	return f.publish(msg)
+}
+
+//Ack takes msg out of the frontier, once we are done with it
+func (f *Frontier) Ack(msg queue.Message) error {
+	err := f.storage.RemoveFrontier(couchdb.FrontierID(msg.URL))
+	if err == couchdb.Error404 {
+		return nil
+	}
+	return err
+}
+
+//Defer keeps msg in the frontier for when we send it back to the queue ourselves
+//after wait, so it's not lost if we go away before that
+func (f *Frontier) Defer(msg queue.Message, wait time.Duration) error {
+	now := time.Now().UTC()
+	id := couchdb.FrontierID(msg.URL)
+	entry, err := f.storage.GetFrontier(id)
+	if err == couchdb.Error404 {
+		entry = couchdb.FrontierEntry{ID: id, URL: msg.URL, Site: msg.Site, QueuedOn: now}
+	} else if err != nil {
+		return err
+	}
+	entry.VisibleAt = now.Add(wait + f.ackTimeout)
+	entry.Message = msg
+	return f.storage.UpdateFrontier(entry)
+}
+
+//Redeliver publishes up to limit entries nobody acknowledged in time, telling us how many it sent.
+//Entries published maxDeliveries times become dead letters
+func (f *Frontier) Redeliver(now time.Time, limit int) (int, error) {
+	due, err := f.storage.DueFrontier(now, limit)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, entry := range due {
+		if entry.Deliveries >= f.maxDeliveries {
+			f.giveUp(entry, now)
+			continue
+		}
+		entry.VisibleAt = now.Add(f.ackTimeout)
+		entry.Deliveries++
+		//Other fetchers may be redelivering the same entry, only the one that saves it publishes it
+		if err := f.storage.UpdateFrontier(entry); err != nil {
+			log.V(3).Infof("Not redelivering %s, got: %v\n", entry.URL, err)
+			continue
+		}
+		if err := f.publish(entry.Message); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+func (f *Frontier) giveUp(entry couchdb.FrontierEntry, now time.Time) {
+	if err := f.storage.RemoveFrontier(entry.ID); err != nil {
+		//Somebody else is on it
+		return
+	}
+	log.Errorf("Giving up on %s after %d deliveries\n", entry.URL, entry.Deliveries)
+	err := f.storage.AddDeadLetter(couchdb.DeadLetter{
+		URL:      entry.URL,
+		Reason:   fmt.Sprintf("Not acknowledged after %d deliveries", entry.Deliveries),
+		Attempts: entry.Deliveries,
+		FailedOn: now,
+		Message:  entry.Message,
+	})
+	if err != nil {
+		log.Errorf("Error recording dead letter for %s, got: %v\n", entry.URL, err)
+	}
+}
+
+func (f *Frontier) publish(msg queue.Message) error {
+	payload, err := queue.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return f.pub.Publish(SubjectFor(msg), payload)
+}
@@ -0,0 +1,112 @@
+package frontier
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/queue"
+	"github.com/fmpwizard/owlcrawler/store"
+)
+
+type publisher struct {
+	sent     []queue.Message
+	subjects []string
+}
+
+func (p *publisher) Publish(subject string, data []byte) error {
+	msg, err := queue.DecodeFetch(data)
+	if err != nil {
+		return err
+	}
+	p.sent = append(p.sent, msg)
+	p.subjects = append(p.subjects, subject)
+	return nil
+}
+
+func TestPushAndAck(t *testing.T) {
+	s := store.NewMemory()
+	pub := &publisher{}
+	f := New(s, pub, time.Minute, 3)
+	msg := queue.Message{URL: "http://example.com/a", Site: "http://example.com"}
+	f.Push(msg)
+	f.Push(msg)
+	if len(pub.sent) != 1 {
+		t.Errorf("Urls already waiting should not be published again. It sent: %+v\n", pub.sent)
+	}
+	if depth, _ := s.FrontierDepth(); depth["http://example.com"] != 1 {
+		t.Errorf("Expected one url waiting for example.com. It gave: %v\n", depth)
+	}
+	if err := f.Ack(msg); err != nil {
+		t.Errorf("Ack failed with: %v\n", err)
+	}
+	if depth, _ := s.FrontierDepth(); len(depth) != 0 {
+		t.Errorf("Acknowledged urls should leave the frontier. It gave: %v\n", depth)
+	}
+}
+
+func TestRedeliver(t *testing.T) {
+	s := store.NewMemory()
+	pub := &publisher{}
+	f := New(s, pub, time.Minute, 3)
+	msg := queue.Message{URL: "http://example.com/a"}
+	f.Push(msg)
+
+	now := time.Now()
+	if sent, _ := f.Redeliver(now, 10); sent != 0 {
+		t.Errorf("Nothing should be due before the ack timeout. It sent: %d\n", sent)
+	}
+	if sent, _ := f.Redeliver(now.Add(2*time.Minute), 10); sent != 1 || len(pub.sent) != 2 {
+		t.Errorf("Expected the url to be published again. It sent: %d\n", sent)
+	}
+	f.Redeliver(now.Add(4*time.Minute), 10)
+	f.Redeliver(now.Add(6*time.Minute), 10)
+	if letters, _ := s.DeadLetters(10); len(letters) != 1 || letters[0].URL != msg.URL {
+		t.Errorf("Expected a dead letter after 3 deliveries. It gave: %+v\n", letters)
+	}
+	if _, err := s.GetFrontier(couchdb.FrontierID(msg.URL)); err != couchdb.Error404 {
+		t.Errorf("Dead letters should leave the frontier. It gave: %v\n", err)
+	}
+}
+
+func TestDefer(t *testing.T) {
+	s := store.NewMemory()
+	f := New(s, &publisher{}, time.Minute, 3)
+	msg := queue.Message{URL: "http://example.com/a", Retries: 2}
+	if err := f.Defer(msg, time.Hour); err != nil {
+		t.Fatalf("Defer failed with: %v\n", err)
+	}
+	entry, err := s.GetFrontier(couchdb.FrontierID(msg.URL))
+	if err != nil || entry.Message.Retries != 2 || entry.VisibleAt.Before(time.Now().Add(time.Hour)) {
+		t.Errorf("Deferred url should stay out of the queue for an hour. It gave: %+v, %v\n", entry, err)
+	}
+}
+
+func TestLanes(t *testing.T) {
+	s := store.NewMemory()
+	pub := &publisher{}
+	f := New(s, pub, time.Minute, 3)
+	seed := queue.Message{URL: "http://example.com/", Priority: queue.PriorityHigh}
+	f.Push(seed)
+	f.Push(seed.Child("http://example.com/blog?page=2"))
+	f.Push(seed.Child("http://example.com/about"))
+	expected := []string{"fetch_url.high", "fetch_url.low", "fetch_url"}
+	for i, subject := range expected {
+		if i >= len(pub.subjects) || pub.subjects[i] != subject {
+			t.Errorf("Expected %s on %s. It gave: %v\n", pub.sent[i].URL, subject, pub.subjects)
+		}
+	}
+
+	f.Push(queue.Message{URL: "http://example.com/about", Priority: queue.PriorityNow})
+	if len(pub.subjects) != 4 || pub.subjects[3] != "fetch_url.now" {
+		t.Errorf("A higher priority should publish the url again. It gave: %v\n", pub.subjects)
+	}
+	f.Push(queue.Message{URL: "http://example.com/about", Depth: 1})
+	if len(pub.subjects) != 4 {
+		t.Errorf("A lower priority should not publish the url again. It gave: %v\n", pub.subjects)
+	}
+	entry, _ := s.GetFrontier(couchdb.FrontierID("http://example.com/about"))
+	if entry.Message.Priority != queue.PriorityNow {
+		t.Errorf("The frontier should keep the higher priority. It gave: %+v\n", entry.Message)
+	}
+}
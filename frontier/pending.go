@@ -0,0 +1,94 @@
+package frontier
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/fmpwizard/owlcrawler/queue"
+)
+
+//Pending holds the urls a fetcher took from the queue but didn't hand to a worker yet,
+//the highest ranked first and, within the same rank, in the order they came.
+//At most PerHost urls of the same host wait, so a busy host can't take the place
+//of the others, 0 means no limit. It's not safe for concurrent use
+type Pending struct {
+	PerHost int
+	msgs    []queue.Message
+	hosts   map[string]int
+}
+
+//Push adds msg after every url ranked the same or higher. When its host already
+//has PerHost urls waiting, the lowest ranked of them, maybe msg, is taken out
+//and returned with full set
+func (p *Pending) Push(msg queue.Message) (overflow queue.Message, full bool) {
+	rank := msg.Rank()
+	i := sort.Search(len(p.msgs), func(i int) bool { return p.msgs[i].Rank() < rank })
+	p.msgs = append(p.msgs, queue.Message{})
+	copy(p.msgs[i+1:], p.msgs[i:])
+	p.msgs[i] = msg
+	if p.hosts == nil {
+		p.hosts = make(map[string]int)
+	}
+	host := hostOf(msg.URL)
+	p.hosts[host]++
+	if p.PerHost <= 0 || p.hosts[host] <= p.PerHost {
+		return queue.Message{}, false
+	}
+	for j := len(p.msgs) - 1; j >= 0; j-- {
+		if hostOf(p.msgs[j].URL) == host {
+			return p.remove(j), true
+		}
+	}
+	return queue.Message{}, false
+}
+
+//Len is how many urls are waiting
+func (p *Pending) Len() int {
+	return len(p.msgs)
+}
+
+//Pop takes out the highest ranked url eligible says we can fetch now
+func (p *Pending) Pop(eligible func(queue.Message) bool) (queue.Message, bool) {
+	for i, msg := range p.msgs {
+		if eligible(msg) {
+			return p.remove(i), true
+		}
+	}
+	return queue.Message{}, false
+}
+
+//PopLowest takes out the lowest ranked url, the last one that came of them
+func (p *Pending) PopLowest() (queue.Message, bool) {
+	if len(p.msgs) == 0 {
+		return queue.Message{}, false
+	}
+	return p.remove(len(p.msgs) - 1), true
+}
+
+//Drain takes out every url waiting
+func (p *Pending) Drain() []queue.Message {
+	msgs := p.msgs
+	p.msgs = nil
+	p.hosts = nil
+	return msgs
+}
+
+func (p *Pending) remove(i int) queue.Message {
+	msg := p.msgs[i]
+	p.msgs = append(p.msgs[:i], p.msgs[i+1:]...)
+	host := hostOf(msg.URL)
+	if p.hosts[host]--; p.hosts[host] <= 0 {
+		delete(p.hosts, host)
+	}
+	return msg
+}
+
+//hostOf is the host we count the urls of, invalid urls count as the same host
+func hostOf(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,65 @@
+package frontier
+
+import (
+	"testing"
+
+	"github.com/fmpwizard/owlcrawler/queue"
+)
+
+func TestPendingOrder(t *testing.T) {
+	var p Pending
+	p.Push(queue.Message{URL: "http://a.com/deep", Depth: 3})
+	p.Push(queue.Message{URL: "http://b.com/", Priority: queue.PriorityHigh})
+	p.Push(queue.Message{URL: "http://a.com/first", Depth: 1})
+	p.Push(queue.Message{URL: "http://b.com/second", Depth: 1})
+	p.Push(queue.Message{URL: "http://a.com/now", Priority: queue.PriorityNow, Depth: 4})
+
+	all := func(queue.Message) bool { return true }
+	notA := func(msg queue.Message) bool { return msg.URL[:12] != "http://a.com" }
+	if msg, _ := p.Pop(notA); msg.URL != "http://b.com/" {
+		t.Errorf("Expected the highest ranked url of an eligible host. It gave: %s\n", msg.URL)
+	}
+	expected := []string{"http://a.com/now", "http://a.com/first", "http://b.com/second", "http://a.com/deep"}
+	for _, url := range expected {
+		if msg, ok := p.Pop(all); !ok || msg.URL != url {
+			t.Errorf("Expected %s. It gave: %s\n", url, msg.URL)
+		}
+	}
+	if _, ok := p.Pop(all); ok || p.Len() != 0 {
+		t.Errorf("Nothing should be left\n")
+	}
+}
+
+func TestPendingDrain(t *testing.T) {
+	var p Pending
+	p.Push(queue.Message{URL: "http://a.com/"})
+	p.Push(queue.Message{URL: "http://b.com/"})
+	if _, ok := p.Pop(func(queue.Message) bool { return false }); ok {
+		t.Errorf("Nothing was eligible\n")
+	}
+	if msgs := p.Drain(); len(msgs) != 2 || p.Len() != 0 {
+		t.Errorf("Drain should take out everything. It gave: %+v\n", msgs)
+	}
+}
+
+func TestPendingPerHost(t *testing.T) {
+	p := Pending{PerHost: 2}
+	p.Push(queue.Message{URL: "http://a.com/1"})
+	p.Push(queue.Message{URL: "http://a.com/2", Depth: 2})
+	if overflow, full := p.Push(queue.Message{URL: "http://A.com/3", Depth: 1}); !full || overflow.URL != "http://a.com/2" {
+		t.Errorf("The lowest ranked url of a full host should be taken out. It gave: %+v, %t\n", overflow, full)
+	}
+	if overflow, full := p.Push(queue.Message{URL: "http://a.com/4", Depth: 3}); !full || overflow.URL != "http://a.com/4" {
+		t.Errorf("Lower ranked urls of a full host should not get in. It gave: %+v, %t\n", overflow, full)
+	}
+	if _, full := p.Push(queue.Message{URL: "http://b.com/1", Depth: 5}); full || p.Len() != 3 {
+		t.Errorf("Other hosts should still get in\n")
+	}
+	if msg, _ := p.PopLowest(); msg.URL != "http://b.com/1" {
+		t.Errorf("Expected the lowest ranked url. It gave: %s\n", msg.URL)
+	}
+	p.Pop(func(queue.Message) bool { return true })
+	if _, full := p.Push(queue.Message{URL: "http://a.com/5"}); full {
+		t.Errorf("Taking a url out should make room for its host\n")
+	}
+}
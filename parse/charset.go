@@ -0,0 +1,44 @@
+package parse
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+//ToUTF8 transcodes a page to UTF-8, returning the name of the encoding it was in.
+//The encoding comes from a byte order mark, the Content-Type header or a
+//<meta charset> / http-equiv tag, in that order. Without any of them we
+//guess from the content. The guess is windows-1252 for plain ASCII pages too,
+//we call those UTF-8 so we don't record an encoding they never used
+func ToUTF8(body []byte, contentType string) ([]byte, string, error) {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	if !certain && name == "windows-1252" && isASCII(body) {
+		return body, "utf-8", nil
+	}
+	if name == "utf-8" {
+		body = bytes.TrimPrefix(body, utf8BOM)
+		if utf8.Valid(body) {
+			return body, name, nil
+		}
+		//Invalid bytes become U+FFFD instead of ending up in the index
+		return bytes.ToValidUTF8(body, []byte("�")), name, nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, name, err
+	}
+	return decoded, name, nil
+}
+
+func isASCII(body []byte) bool {
+	for _, b := range body {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestToUTF8(t *testing.T) {
+	tests := []struct {
+		body        []byte
+		contentType string
+		charset     string
+		expected    string
+	}{
+		//Latin-1 from the header
+		{[]byte("<p>caf\xe9</p>"), "text/html; charset=ISO-8859-1", "windows-1252", "<p>café</p>"},
+		//Shift-JIS from a meta tag
+		{[]byte("<meta charset=\"shift_jis\"><p>\x93\xfa\x96\x7b</p>"), "text/html", "shift_jis", "<meta charset=\"shift_jis\"><p>日本</p>"},
+		//http-equiv
+		{[]byte("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=windows-1252\"><p>\x93quoted\x94</p>"), "text/html", "windows-1252", "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=windows-1252\"><p>“quoted”</p>"},
+		//The BOM wins over the header and is dropped
+		{[]byte("\xef\xbb\xbf<p>café</p>"), "text/html; charset=ISO-8859-1", "utf-8", "<p>café</p>"},
+		//Nothing to go on, but valid UTF-8
+		{[]byte("<p>café</p>"), "text/html", "utf-8", "<p>café</p>"},
+		//Plain ASCII is UTF-8, not the windows-1252 we would guess
+		{[]byte("<p>cafe</p>"), "text/html", "utf-8", "<p>cafe</p>"},
+	}
+	for _, test := range tests {
+		got, name, err := ToUTF8(test.body, test.contentType)
+		if err != nil {
+			t.Errorf("ToUTF8 failed with: %v\n", err)
+			continue
+		}
+		if name != test.charset || string(got) != test.expected {
+			t.Errorf("Expected %s %q. It gave: %s %q\n", test.charset, test.expected, name, got)
+		}
+	}
+}
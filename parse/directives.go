@@ -0,0 +1,93 @@
+package parse
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//Directives are what a page asks crawlers to do with it, through robots meta tags
+//or X-Robots-Tag headers
+type Directives struct {
+	//NoIndex pages are stored but left out of search
+	NoIndex bool `json:"noindex,omitempty"`
+	//NoFollow pages have their links stored but not fetched
+	NoFollow bool `json:"nofollow,omitempty"`
+}
+
+//Merge combines two sets of directives, the most restrictive wins
+func (d Directives) Merge(other Directives) Directives {
+	return Directives{
+		NoIndex:  d.NoIndex || other.NoIndex,
+		NoFollow: d.NoFollow || other.NoFollow,
+	}
+}
+
+//headerOnlyDirectives are X-Robots-Tag directives that have a colon in them,
+//so we don't take them for a user agent name
+var headerOnlyDirectives = []string{"unavailable_after", "max-snippet", "max-image-preview", "max-video-preview"}
+
+//MetaRobots reads the <meta name="robots"> tags of a page, and the ones named after agent
+func MetaRobots(payload string, agent string) Directives {
+	var ret Directives
+	agent = strings.ToLower(agent)
+	d := html.NewTokenizer(strings.NewReader(payload))
+	for {
+		tokenType := d.Next()
+		if tokenType == html.ErrorToken {
+			return ret
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := d.Token()
+		if token.DataAtom != atom.Meta {
+			continue
+		}
+		name, _ := attr(token, "name")
+		name = strings.ToLower(name)
+		if name != "robots" && name != agent {
+			continue
+		}
+		content, _ := attr(token, "content")
+		ret = ret.Merge(parseDirectives(content))
+	}
+}
+
+//HeaderRobots reads X-Robots-Tag header values. Values starting with a user agent
+//name, like "googlebot: noindex", only count when the name is agent
+func HeaderRobots(values []string, agent string) Directives {
+	var ret Directives
+	agent = strings.ToLower(agent)
+	for _, value := range values {
+		if i := strings.Index(value, ":"); i > 0 {
+			name := strings.ToLower(strings.TrimSpace(value[:i]))
+			if !contains(headerOnlyDirectives, name) {
+				if name != agent {
+					continue
+				}
+				value = value[i+1:]
+			}
+		}
+		ret = ret.Merge(parseDirectives(value))
+	}
+	return ret
+}
+
+//parseDirectives reads a comma separated list like "noindex, nofollow"
+func parseDirectives(content string) Directives {
+	var ret Directives
+	for _, directive := range strings.Split(content, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			ret.NoIndex = true
+		case "nofollow":
+			ret.NoFollow = true
+		case "none":
+			ret.NoIndex = true
+			ret.NoFollow = true
+		}
+	}
+	return ret
+}
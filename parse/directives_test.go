@@ -0,0 +1,32 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestMetaRobots(t *testing.T) {
+	page := `<html><head>
+<meta name="ROBOTS" content="noindex">
+<meta name="googlebot" content="nofollow">
+</head><body></body></html>`
+	if d := MetaRobots(page, "OwlCrawler"); !d.NoIndex || d.NoFollow {
+		t.Errorf("Expected only noindex. It gave: %+v\n", d)
+	}
+	page = `<html><head><meta name="owlcrawler" content="none"></head></html>`
+	if d := MetaRobots(page, "OwlCrawler"); !d.NoIndex || !d.NoFollow {
+		t.Errorf("Meta tags named after us should count. It gave: %+v\n", d)
+	}
+	if d := MetaRobots(doc1, "OwlCrawler"); d.NoIndex || d.NoFollow {
+		t.Errorf("Expected no directives. It gave: %+v\n", d)
+	}
+}
+
+func TestHeaderRobots(t *testing.T) {
+	values := []string{"googlebot: noindex", "unavailable_after: 25 Jun 2010 15:00:00 PST", "OwlCrawler: nofollow"}
+	if d := HeaderRobots(values, "OwlCrawler"); d.NoIndex || !d.NoFollow {
+		t.Errorf("Expected only nofollow. It gave: %+v\n", d)
+	}
+	if d := HeaderRobots([]string{"noindex, NOFOLLOW"}, "OwlCrawler"); !d.NoIndex || !d.NoFollow {
+		t.Errorf("Expected noindex and nofollow. It gave: %+v\n", d)
+	}
+}
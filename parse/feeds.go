@@ -0,0 +1,63 @@
+package parse
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//feedTypes are the <link type> values of the feeds we read
+var feedTypes = []string{"application/rss+xml", "application/atom+xml"}
+
+//ExtractFeeds finds the RSS and Atom feeds a page announces with
+//<link rel="alternate" type="application/rss+xml" href="...">
+func ExtractFeeds(payload string, originalURL string) []string {
+	base, err := url.Parse(originalURL)
+	if err != nil {
+		return nil
+	}
+	var feeds []string
+	d := html.NewTokenizer(strings.NewReader(payload))
+	for {
+		tokenType := d.Next()
+		if tokenType == html.ErrorToken {
+			return feeds
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := d.Token()
+		if token.DataAtom != atom.Link || !isFeedLink(token) {
+			continue
+		}
+		href, _ := attr(token, "href")
+		ref, err := url.Parse(href)
+		if err != nil || href == "" {
+			continue
+		}
+		link, err := NormalizeURL(base.ResolveReference(ref).String())
+		if err == nil && !contains(feeds, link) {
+			feeds = append(feeds, link)
+		}
+	}
+}
+
+func isFeedLink(token html.Token) bool {
+	rel, _ := attr(token, "rel")
+	if !contains(strings.Fields(strings.ToLower(rel)), "alternate") {
+		return false
+	}
+	linkType, _ := attr(token, "type")
+	return contains(feedTypes, strings.ToLower(linkType))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package parse
+
+import (
+	"testing"
+)
+
+var feedsDoc = `<html>
+<head>
+  <link rel="alternate" type="application/rss+xml" title="Posts" href="/feed.xml">
+  <link rel="Alternate" type="application/atom+xml" href="http://example.com/atom.xml">
+  <link rel="alternate" type="application/rss+xml" href="/feed.xml">
+  <link rel="alternate" hreflang="es" href="/es/">
+  <link rel="stylesheet" type="text/css" href="/site.css">
+</head>
+<body><a href="/rss.xml">RSS</a></body>
+</html>`
+
+func TestExtractFeeds(t *testing.T) {
+	feeds := ExtractFeeds(feedsDoc, "http://example.com/blog/post")
+	if len(feeds) != 2 || feeds[0] != "http://example.com/feed.xml" || feeds[1] != "http://example.com/atom.xml" {
+		t.Errorf("Expected the rss and atom feeds once. It gave: %v\n", feeds)
+	}
+	if feeds := ExtractFeeds(doc1, "http://example.com/"); len(feeds) != 0 {
+		t.Errorf("Expected no feeds. It gave: %v\n", feeds)
+	}
+}
@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//Link is a link we found on a page, with what the page says about it
+type Link struct {
+	URL string `json:"url"`
+	//Text is the anchor text, or the alt text of the images inside the link
+	Text  string `json:"text,omitempty"`
+	Title string `json:"title,omitempty"`
+	//Rel are the lowercased values of the rel attribute
+	Rel      []string `json:"rel,omitempty"`
+	NoFollow bool     `json:"nofollow,omitempty"`
+	//External links point to another host than the page
+	External bool `json:"external,omitempty"`
+	//Position is the order of the link on the page, starting at 0
+	Position int `json:"position"`
+	//Section is the innermost nav, header, footer, aside, main or article element
+	//the link is in, if any
+	Section string `json:"section,omitempty"`
+}
+
+//sections are the elements we record as the Section of the links inside them
+var sections = []atom.Atom{atom.Nav, atom.Header, atom.Footer, atom.Aside, atom.Main, atom.Article}
+
+//UnmarshalJSON also reads the plain urls older documents have as links
+func (l *Link) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*l = Link{}
+		return json.Unmarshal(data, &l.URL)
+	}
+	type plain Link
+	return json.Unmarshal(data, (*plain)(l))
+}
+
+//newLink describes the link to target in token, found on the page at pageURL
+func newLink(token html.Token, target string, pageURL *url.URL, position int, section atom.Atom) Link {
+	title, _ := attr(token, "title")
+	rel, _ := attr(token, "rel")
+	link := Link{
+		URL:      target,
+		Title:    title,
+		Rel:      strings.Fields(strings.ToLower(rel)),
+		NoFollow: isNoFollow(token),
+		Position: position,
+		Section:  section.String(),
+	}
+	if ref, err := url.Parse(target); err == nil && isFetchable(ref) {
+		link.External = !strings.EqualFold(ref.Hostname(), pageURL.Hostname())
+	}
+	return link
+}
+
+//addText appends text to the anchor text of l, collapsing whitespace
+func (l *Link) addText(text string) {
+	l.Text = strings.Join(strings.Fields(l.Text+" "+text), " ")
+}
+
+//innermostSection is the last section element still open, if any
+func innermostSection(open []atom.Atom) atom.Atom {
+	if len(open) == 0 {
+		return 0
+	}
+	return open[len(open)-1]
+}
+
+//closeSection takes the innermost a out of the open sections
+func closeSection(open []atom.Atom, a atom.Atom) []atom.Atom {
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i] == a {
+			return append(open[:i], open[i+1:]...)
+		}
+	}
+	return open
+}
+
+//isSection tells us if a is one of the elements we record as a link Section
+func isSection(a atom.Atom) bool {
+	for _, s := range sections {
+		if s == a {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package parse
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var doc5 = `<html><body>
+<nav><a href="/" title="Home page">Home</a></nav>
+<main><article><p>Read <a href="/guide.html">the
+  <b>owl</b> guide</a></p>
+<a href="http://other.example.org/" rel="External NoFollow"><img src="logo.png" alt="Other site"></a>
+</article></main>
+<footer><a href="mailto:owl@example.com">Mail us</a></footer>
+</body></html>`
+
+func TestExtractLinksRecords(t *testing.T) {
+	toFetch, toStore := ExtractLinks(doc5, "http://example.com/docs/", mockedFetchChecker)
+	expected := []Link{
+		{URL: "http://example.com/", Text: "Home", Title: "Home page", Position: 0, Section: "nav"},
+		{URL: "http://example.com/guide.html", Text: "the owl guide", Position: 1, Section: "article"},
+		{URL: "http://other.example.org/", Text: "Other site", Rel: []string{"external", "nofollow"}, NoFollow: true, External: true, Position: 2, Section: "article"},
+		{URL: "mailto:owl@example.com", Text: "Mail us", Position: 3, Section: "footer"},
+	}
+	if len(toStore.Links) != len(expected) || len(toStore.URL) != len(expected) {
+		t.Fatalf("ExtractLinks didn't give us expected result. It gave: %+v\n", toStore.Links)
+	}
+	for i, link := range expected {
+		got, _ := json.Marshal(toStore.Links[i])
+		want, _ := json.Marshal(link)
+		if string(got) != string(want) {
+			t.Errorf("Expected %s. It gave: %s\n", want, got)
+		}
+	}
+	if len(toFetch.Links) != 2 || toFetch.Links[1].Text != "the owl guide" {
+		t.Errorf("Links to fetch should keep their records. It gave: %+v\n", toFetch.Links)
+	}
+}
+
+func TestLinkUnmarshalPlainURL(t *testing.T) {
+	var links []Link
+	if err := json.Unmarshal([]byte(`["http://example.com/a", {"url": "http://example.com/b", "text": "B"}]`), &links); err != nil {
+		t.Fatalf("Could not read links, got: %v\n", err)
+	}
+	if len(links) != 2 || links[0].URL != "http://example.com/a" || links[1].Text != "B" {
+		t.Errorf("Plain urls and records should both be read. It gave: %+v\n", links)
+	}
+}
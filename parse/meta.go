@@ -0,0 +1,134 @@
+package parse
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//Meta is what a page says about itself in its <html>, <meta> and <link> tags.
+//It's stored with the rest of the PageStructure
+type Meta struct {
+	Description string   `json:"description,omitempty"`
+	Keywords    []string `json:"keywords,omitempty"`
+	Author      string   `json:"author,omitempty"`
+	//Canonical is the url the page says it should be known by
+	Canonical string `json:"canonical,omitempty"`
+	//Lang comes from <html lang>, or the content-language meta tag
+	Lang string `json:"lang,omitempty"`
+	//Alternates are the translations of the page, from <link rel="alternate" hreflang>
+	Alternates []Alternate `json:"alternates,omitempty"`
+	//OpenGraph and Twitter are the og: and twitter: properties, without the prefix
+	OpenGraph map[string]string `json:"opengraph,omitempty"`
+	Twitter   map[string]string `json:"twitter,omitempty"`
+	Favicon   string            `json:"favicon,omitempty"`
+}
+
+//Alternate is a version of the page in another language or region
+type Alternate struct {
+	Lang string `json:"hreflang"`
+	URL  string `json:"url"`
+}
+
+//ExtractMeta reads the metadata of a page. Urls are resolved against the
+//page url, or the document's <base href> if it has one. When a tag is repeated
+//the first one wins
+func ExtractMeta(payload string, originalURL string) Meta {
+	var meta Meta
+	base, err := url.Parse(originalURL)
+	if err != nil {
+		return meta
+	}
+	seenBase := false
+
+	d := html.NewTokenizer(strings.NewReader(payload))
+	for {
+		tokenType := d.Next()
+		if tokenType == html.ErrorToken {
+			return meta
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := d.Token()
+		switch token.DataAtom {
+		case atom.Html:
+			if lang, _ := attr(token, "lang"); meta.Lang == "" {
+				meta.Lang = lang
+			}
+		case atom.Base:
+			if href, ok := attr(token, "href"); ok && !seenBase {
+				if ref, err := url.Parse(href); err == nil {
+					base = base.ResolveReference(ref)
+					seenBase = true
+				}
+			}
+		case atom.Meta:
+			meta.addMeta(token)
+		case atom.Link:
+			rel, _ := attr(token, "rel")
+			rels := strings.Fields(strings.ToLower(rel))
+			href, _ := attr(token, "href")
+			if contains(rels, "canonical") && meta.Canonical == "" {
+				meta.Canonical = resolveURL(base, href)
+			}
+			if contains(rels, "icon") && meta.Favicon == "" {
+				meta.Favicon = resolveURL(base, href)
+			}
+			lang, _ := attr(token, "hreflang")
+			if contains(rels, "alternate") && lang != "" {
+				if link := resolveURL(base, href); link != "" {
+					meta.Alternates = append(meta.Alternates, Alternate{Lang: lang, URL: link})
+				}
+			}
+		}
+	}
+}
+
+//addMeta records a <meta> tag, OpenGraph uses property= and the rest name=,
+//though pages mix them up
+func (meta *Meta) addMeta(token html.Token) {
+	content, _ := attr(token, "content")
+	if content == "" {
+		return
+	}
+	name, ok := attr(token, "property")
+	if !ok {
+		name, _ = attr(token, "name")
+	}
+	if name == "" {
+		name, _ = attr(token, "http-equiv")
+	}
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(name, "og:"):
+		meta.OpenGraph = addProperty(meta.OpenGraph, name[len("og:"):], content)
+	case strings.HasPrefix(name, "twitter:"):
+		meta.Twitter = addProperty(meta.Twitter, name[len("twitter:"):], content)
+	case name == "description" && meta.Description == "":
+		meta.Description = content
+	case name == "author" && meta.Author == "":
+		meta.Author = content
+	case name == "keywords" && meta.Keywords == nil:
+		for _, keyword := range strings.Split(content, ",") {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				meta.Keywords = append(meta.Keywords, keyword)
+			}
+		}
+	case name == "content-language" && meta.Lang == "":
+		meta.Lang = content
+	}
+}
+
+//addProperty sets key in properties unless it's there already, creating the map if needed
+func addProperty(properties map[string]string, key string, value string) map[string]string {
+	if properties == nil {
+		properties = make(map[string]string)
+	}
+	if _, ok := properties[key]; !ok {
+		properties[key] = value
+	}
+	return properties
+}
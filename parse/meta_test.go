@@ -0,0 +1,50 @@
+package parse
+
+import (
+	"testing"
+)
+
+var doc6 = `<html lang="en-US"><head>
+<base href="http://example.com/en/">
+<title>Owls</title>
+<meta name="Description" content="All about owls">
+<meta name="description" content="Not this one">
+<meta name="keywords" content="owls, birds, ,night">
+<meta name="author" content="Jane Owl">
+<meta property="og:title" content="Owls!">
+<meta property="og:image" content="http://example.com/owl.png">
+<meta name="twitter:card" content="summary">
+<link rel="canonical" href="owls.html?utm_source=feed">
+<link rel="shortcut icon" href="/favicon.ico">
+<link rel="alternate" hreflang="es" href="/es/buhos.html">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+</head><body><p>Hoot</p></body></html>`
+
+func TestExtractMeta(t *testing.T) {
+	meta := ExtractMeta(doc6, "http://example.com/en/index.html")
+	if meta.Description != "All about owls" || meta.Author != "Jane Owl" || meta.Lang != "en-US" {
+		t.Errorf("Wrong description, author or lang. It gave: %+v\n", meta)
+	}
+	if len(meta.Keywords) != 3 || meta.Keywords[2] != "night" {
+		t.Errorf("Wrong keywords. It gave: %+v\n", meta.Keywords)
+	}
+	if meta.Canonical != "http://example.com/en/owls.html" {
+		t.Errorf("Canonical should be resolved and normalized. It gave: %s\n", meta.Canonical)
+	}
+	if meta.Favicon != "http://example.com/favicon.ico" {
+		t.Errorf("Wrong favicon. It gave: %s\n", meta.Favicon)
+	}
+	if len(meta.Alternates) != 1 || meta.Alternates[0] != (Alternate{Lang: "es", URL: "http://example.com/es/buhos.html"}) {
+		t.Errorf("Only hreflang alternates should be kept. It gave: %+v\n", meta.Alternates)
+	}
+	if meta.OpenGraph["title"] != "Owls!" || meta.OpenGraph["image"] != "http://example.com/owl.png" || meta.Twitter["card"] != "summary" {
+		t.Errorf("Wrong OpenGraph or Twitter properties. It gave: %+v %+v\n", meta.OpenGraph, meta.Twitter)
+	}
+}
+
+func TestExtractMetaContentLanguage(t *testing.T) {
+	page := `<html><head><meta http-equiv="Content-Language" content="fr"></head></html>`
+	if meta := ExtractMeta(page, "http://example.com/"); meta.Lang != "fr" {
+		t.Errorf("Expected the content-language as lang. It gave: %+v\n", meta)
+	}
+}
@@ -0,0 +1,196 @@
+package parse
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+//NormalizeOptions controls the optional steps of NormalizeURL
+type NormalizeOptions struct {
+	//SortQuery sorts query parameters by name
+	SortQuery bool
+	//StripTracking removes query parameters listed in TrackingParams,
+	//a trailing * matches any parameter with that prefix
+	StripTracking  bool
+	TrackingParams []string
+}
+
+//DefaultNormalizeOptions are the options NormalizeURL uses
+var DefaultNormalizeOptions = NormalizeOptions{
+	SortQuery:     true,
+	StripTracking: true,
+	TrackingParams: []string{
+		"utm_*", "gclid", "fbclid", "msclkid", "mc_cid", "mc_eid", "_ga", "yclid",
+	},
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+//NormalizeURL returns the canonical form of rawurl, so the same page always gets the
+//same document id no matter how it was linked to
+func NormalizeURL(rawurl string) (string, error) {
+	return NormalizeURLWith(rawurl, DefaultNormalizeOptions)
+}
+
+//NormalizeURLWith is NormalizeURL with explicit options
+func NormalizeURLWith(rawurl string, opts NormalizeOptions) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil {
+		return "", err
+	}
+	if u.Opaque != "" {
+		return u.String(), nil
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		host = host + ":" + port
+	}
+
+	p := removeDotSegments(normalizePercent(u.EscapedPath()))
+	if p == "" && host != "" {
+		p = "/"
+	}
+
+	var ret strings.Builder
+	if scheme != "" {
+		ret.WriteString(scheme + ":")
+	}
+	if host != "" || u.User != nil {
+		ret.WriteString("//")
+		if u.User != nil {
+			ret.WriteString(u.User.String() + "@")
+		}
+		ret.WriteString(host)
+	}
+	ret.WriteString(p)
+	if q := normalizeQuery(u.RawQuery, opts); q != "" {
+		ret.WriteString("?" + q)
+	}
+	return ret.String(), nil
+}
+
+func normalizeQuery(rawQuery string, opts NormalizeOptions) string {
+	if rawQuery == "" {
+		return ""
+	}
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		if opts.StripTracking && isTrackingParam(param, opts.TrackingParams) {
+			continue
+		}
+		params = append(params, normalizePercent(param))
+	}
+	if opts.SortQuery {
+		sort.SliceStable(params, func(i, j int) bool {
+			return paramName(params[i]) < paramName(params[j])
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+func paramName(param string) string {
+	if idx := strings.Index(param, "="); idx >= 0 {
+		return param[:idx]
+	}
+	return param
+}
+
+func isTrackingParam(param string, tracking []string) bool {
+	name, err := url.QueryUnescape(paramName(param))
+	if err != nil {
+		return false
+	}
+	name = strings.ToLower(name)
+	for _, t := range tracking {
+		if strings.HasSuffix(t, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+		} else if name == t {
+			return true
+		}
+	}
+	return false
+}
+
+//normalizePercent decodes percent-encoded unreserved characters and
+//uppercases the hex digits of everything else that stays encoded
+func normalizePercent(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var ret strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				ret.WriteByte(c)
+			} else {
+				ret.WriteString("%" + strings.ToUpper(s[i+1:i+3]))
+			}
+			i += 2
+			continue
+		}
+		ret.WriteByte(s[i])
+	}
+	return ret.String()
+}
+
+//removeDotSegments implements section 5.2.4 of RFC 3986
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	var out []string
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
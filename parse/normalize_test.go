@@ -0,0 +1,37 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	cases := map[string]string{
+		"http://Example.com/a":                          "http://example.com/a",
+		"HTTP://example.com:80/a":                       "http://example.com/a",
+		"https://example.com:443/a":                     "https://example.com/a",
+		"http://example.com:8080/a":                     "http://example.com:8080/a",
+		"http://example.com/a#top":                      "http://example.com/a",
+		"http://example.com/a?b=1&a=2":                  "http://example.com/a?a=2&b=1",
+		"http://example.com":                            "http://example.com/",
+		"http://example.com/a/./b/../c":                 "http://example.com/a/c",
+		"http://example.com/%7euser/%2f%3a":             "http://example.com/~user/%2F%3A",
+		"http://example.com/a?utm_source=x&id=3&gclid=": "http://example.com/a?id=3",
+		"http://example.com/a?utm_source=x":             "http://example.com/a",
+	}
+	for raw, expected := range cases {
+		got, err := NormalizeURL(raw)
+		if err != nil {
+			t.Errorf("NormalizeURL(%s) failed with: %v\n", raw, err)
+		}
+		if got != expected {
+			t.Errorf("NormalizeURL(%s) should be %s. It gave: %s\n", raw, expected, got)
+		}
+	}
+}
+
+func TestNormalizeURLWithoutOptionalSteps(t *testing.T) {
+	got, _ := NormalizeURLWith("http://example.com/a?utm_source=x&b=1&a=2", NormalizeOptions{})
+	if got != "http://example.com/a?utm_source=x&b=1&a=2" {
+		t.Errorf("NormalizeURLWith changed the query. It gave: %s\n", got)
+	}
+}
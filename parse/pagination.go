@@ -0,0 +1,31 @@
+package parse
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+//paginationParams are query parameters sites use to number listing pages
+var paginationParams = []string{"page", "p", "pg", "paged", "offset", "start"}
+
+//paginationPath matches paths like /blog/page/3 or /news/p/2/
+var paginationPath = regexp.MustCompile(`(?i)/(page|p)/\d+/?$`)
+
+//IsPagination tells us if link looks like the second or later page of a listing
+func IsPagination(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	if paginationPath.MatchString(u.Path) {
+		return true
+	}
+	query := u.Query()
+	for _, param := range paginationParams {
+		if n, err := strconv.Atoi(query.Get(param)); err == nil && n > 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package parse
+
+import (
+	"testing"
+)
+
+func TestIsPagination(t *testing.T) {
+	pages := []string{
+		"http://example.com/blog/page/3",
+		"http://example.com/news/p/2/",
+		"http://example.com/list?page=4",
+		"http://example.com/search?q=owl&start=20",
+	}
+	for _, link := range pages {
+		if !IsPagination(link) {
+			t.Errorf("%s should be pagination\n", link)
+		}
+	}
+	others := []string{
+		"http://example.com/page/about",
+		"http://example.com/list?page=1",
+		"http://example.com/list?page=last",
+		"http://example.com/p/owlcrawler",
+	}
+	for _, link := range others {
+		if IsPagination(link) {
+			t.Errorf("%s should not be pagination\n", link)
+		}
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
 
-	"fmt"
 	"net/url"
 	"strings"
 )
@@ -18,12 +17,16 @@ type PageStructure struct {
 	H3    []string `json:"h3,omitempty"`
 	H4    []string `json:"h4,omitempty"`
 	Text  []string `json:"text,omitempty"`
+	//Meta is filled in by ExtractMeta, it needs the page url
+	Meta
 }
 
-//ExtractedLinks holds the current url we parsed and the links extracted from it
+//ExtractedLinks holds the current url we parsed and the links extracted from it.
+//Links describe each of URL, in the same order
 type ExtractedLinks struct {
 	OriginalURL string
 	URL         []string
+	Links       []Link
 }
 
 //URLFetchChecker is a function that tells us if we should fetch a link or not
@@ -84,11 +87,29 @@ Loop:
 	return page
 }
 
-//ExtractLinks gets links from a page
+//ExtractLinks gets links from a page. Relative links are resolved against the
+//page url, or the document's <base href> if it has one.
+//Links with rel="nofollow" are stored but never fetched
 func ExtractLinks(payload string, originalURL string, shouldFetch URLFetchChecker) (toFetch ExtractedLinks, toStore ExtractedLinks) {
-	link, err := url.Parse(originalURL)
+	base, err := url.Parse(originalURL)
 	if err != nil {
 		log.Errorf("Error parsing url %s, got: %v\n", originalURL, err)
+		return toFetch, toStore
+	}
+	page := base
+	toFetch.OriginalURL = originalURL
+	toStore.OriginalURL = originalURL
+	seenBase := false
+	//open is the index in toStore of the link we are in, -1 outside links
+	open := -1
+	var openSections []atom.Atom
+	var fetched []int
+	store := func(token html.Token, link string) {
+		toStore.URL = append(toStore.URL, link)
+		toStore.Links = append(toStore.Links, newLink(token, link, page, len(toStore.Links), innermostSection(openSections)))
+		if token.Type == html.StartTagToken {
+			open = len(toStore.Links) - 1
+		}
 	}
 
 	d := html.NewTokenizer(strings.NewReader(payload))
@@ -100,32 +121,88 @@ Loop:
 		}
 		token := d.Token()
 		switch tokenType {
-		case html.StartTagToken:
-			if token.DataAtom.String() == "a" {
-				for _, attribute := range token.Attr {
-					if attribute.Key == "href" {
-						if strings.HasPrefix(attribute.Val, "//") {
-							url := fmt.Sprintf("%s:%s", link.Scheme, attribute.Val)
-							toStore.URL = append(toStore.URL, url)
-							if shouldFetch(url) {
-								log.V(3).Infof("Sending url: %s\n", url)
-								toFetch.URL = append(toFetch.URL, url)
-							}
-						} else if strings.HasPrefix(attribute.Val, "/") {
-							url := fmt.Sprintf("%s://%s%s", link.Scheme, link.Host, attribute.Val)
-							toStore.URL = append(toStore.URL, url)
-							if shouldFetch(url) {
-								log.V(3).Infof("Sending url: %s\n", url)
-								toFetch.URL = append(toFetch.URL, url)
-							}
-						} else {
-							toStore.URL = append(toStore.URL, attribute.Val)
-							log.V(3).Infof("Simply storing url: %s\n", attribute.Val)
-						}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			if tokenType == html.StartTagToken && isSection(token.DataAtom) {
+				openSections = append(openSections, token.DataAtom)
+			}
+			if token.DataAtom == atom.Base && !seenBase {
+				if href, ok := attr(token, "href"); ok {
+					if ref, err := url.Parse(href); err == nil {
+						base = base.ResolveReference(ref)
+						seenBase = true
 					}
 				}
+			} else if token.DataAtom == atom.Img && open >= 0 {
+				alt, _ := attr(token, "alt")
+				toStore.Links[open].addText(alt)
+			} else if token.DataAtom == atom.A {
+				open = -1
+				href, ok := attr(token, "href")
+				if !ok {
+					continue
+				}
+				ref, err := url.Parse(href)
+				if err != nil || (ref.IsAbs() && !isFetchable(ref)) {
+					store(token, href)
+					log.V(3).Infof("Simply storing url: %s\n", href)
+					continue
+				}
+				resolved := base.ResolveReference(ref)
+				link, err := NormalizeURL(resolved.String())
+				if err != nil {
+					log.Errorf("Error normalizing url %s, got: %v\n", href, err)
+					continue
+				}
+				store(token, link)
+				if isSameDocument(href) || !isFetchable(resolved) || isNoFollow(token) {
+					log.V(3).Infof("Simply storing url: %s\n", link)
+					continue
+				}
+				if shouldFetch(link) {
+					log.V(3).Infof("Sending url: %s\n", link)
+					toFetch.URL = append(toFetch.URL, link)
+					fetched = append(fetched, len(toStore.Links)-1)
+				}
+			}
+		case html.EndTagToken:
+			if token.DataAtom == atom.A {
+				open = -1
+			} else if isSection(token.DataAtom) {
+				openSections = closeSection(openSections, token.DataAtom)
+			}
+		case html.TextToken:
+			if open >= 0 {
+				toStore.Links[open].addText(token.Data)
 			}
 		}
 	}
+	for _, i := range fetched {
+		toFetch.Links = append(toFetch.Links, toStore.Links[i])
+	}
 	return toFetch, toStore
 }
+
+//attr returns the value of the named attribute
+func attr(token html.Token, name string) (string, bool) {
+	for _, attribute := range token.Attr {
+		if attribute.Key == name {
+			return strings.TrimSpace(attribute.Val), true
+		}
+	}
+	return "", false
+}
+
+//isNoFollow tells us if the link asks crawlers not to follow it
+func isNoFollow(token html.Token) bool {
+	rel, _ := attr(token, "rel")
+	return contains(strings.Fields(strings.ToLower(rel)), "nofollow")
+}
+
+//isSameDocument tells us if href only points somewhere inside the current page
+func isSameDocument(href string) bool {
+	return href == "" || strings.HasPrefix(href, "#")
+}
+
+func isFetchable(link *url.URL) bool {
+	return link.Scheme == "http" || link.Scheme == "https"
+}
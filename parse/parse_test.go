@@ -140,7 +140,88 @@ func TestExtractTextNoScript(t *testing.T) {
 
 func TestExtractLinks(t *testing.T) {
 	extracted, _ := ExtractLinks(doc1, "http://drhayleybauman.com", mockedFetchChecker)
-	if len(extracted.URL) != 6 {
+	//6 links on the site and the 3 stores selling the book
+	if len(extracted.URL) != 9 {
 		t.Errorf("ExtractLinks didn't give us expected result. It gave: %d urls\n", len(extracted.URL))
 	}
 }
+
+var doc3 = `<html><head><title>Docs</title></head><body>
+<a href="page.html">Page</a>
+<a href="../about/">About</a>
+<a href="?page=2">Next</a>
+<a href="./sub/./deep/../item.html">Item</a>
+<a href="#top">Top</a>
+<a href="mailto:owl@example.com">Mail</a>
+<a href="//cdn.example.com/file.html">CDN</a>
+</body></html>`
+
+var doc4 = `<html><head><base href="http://example.com/base/dir/"></head><body>
+<a href="page.html">Page</a>
+<a href="/root.html">Root</a>
+</body></html>`
+
+func TestExtractLinksRelative(t *testing.T) {
+	extracted, _ := ExtractLinks(doc3, "http://example.com/docs/guide/index.html", mockedFetchChecker)
+	expected := []string{
+		"http://example.com/docs/guide/page.html",
+		"http://example.com/docs/about/",
+		"http://example.com/docs/guide/index.html?page=2",
+		"http://example.com/docs/guide/sub/item.html",
+		"http://cdn.example.com/file.html",
+	}
+	if len(extracted.URL) != len(expected) {
+		t.Fatalf("ExtractLinks didn't give us expected result. It gave: %+v\n", extracted.URL)
+	}
+	for i, u := range expected {
+		if extracted.URL[i] != u {
+			t.Errorf("Expected %s. It gave: %s\n", u, extracted.URL[i])
+		}
+	}
+}
+
+func TestExtractLinksNoFollow(t *testing.T) {
+	page := `<html><body><a href="/a">A</a><a rel="external NoFollow" href="/b">B</a></body></html>`
+	toFetch, toStore := ExtractLinks(page, "http://example.com/", mockedFetchChecker)
+	if len(toFetch.URL) != 1 || toFetch.URL[0] != "http://example.com/a" {
+		t.Errorf("Nofollow links should not be fetched. It gave: %+v\n", toFetch.URL)
+	}
+	if len(toStore.URL) != 2 {
+		t.Errorf("Nofollow links should still be stored. It gave: %+v\n", toStore.URL)
+	}
+}
+
+func TestExtractLinksAbsolute(t *testing.T) {
+	page := `<html><body>
+<a href="http://example.com/about">About</a>
+<a href="HTTPS://Blog.Example.com/x?utm_source=home">Blog</a>
+<a href="mailto:owl@example.com">Mail</a>
+<a href="http://example.com/#top">Top</a>
+</body></html>`
+	toFetch, toStore := ExtractLinks(page, "http://example.com/rel", mockedFetchChecker)
+	expected := []string{"http://example.com/about", "https://blog.example.com/x", "http://example.com/"}
+	if len(toFetch.URL) != len(expected) {
+		t.Fatalf("Absolute http links should be fetched. It gave: %+v\n", toFetch.URL)
+	}
+	for i, u := range expected {
+		if toFetch.URL[i] != u {
+			t.Errorf("Expected %s. It gave: %s\n", u, toFetch.URL[i])
+		}
+	}
+	if len(toStore.URL) != 4 || toStore.URL[2] != "mailto:owl@example.com" {
+		t.Errorf("Other schemes should only be stored. It gave: %+v\n", toStore.URL)
+	}
+}
+
+func TestExtractLinksBaseHref(t *testing.T) {
+	extracted, _ := ExtractLinks(doc4, "http://example.com/other/page.html", mockedFetchChecker)
+	if len(extracted.URL) != 2 {
+		t.Fatalf("ExtractLinks didn't give us expected result. It gave: %+v\n", extracted.URL)
+	}
+	if extracted.URL[0] != "http://example.com/base/dir/page.html" {
+		t.Errorf("ExtractLinks ignored <base href>. It gave: %s\n", extracted.URL[0])
+	}
+	if extracted.URL[1] != "http://example.com/root.html" {
+		t.Errorf("ExtractLinks didn't resolve against the base host. It gave: %s\n", extracted.URL[1])
+	}
+}
@@ -0,0 +1,309 @@
+package parse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/golang/glog"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+//Sources of structured data, the syntax an Item was written in
+const (
+	SourceJSONLD    = "json-ld"
+	SourceMicrodata = "microdata"
+	SourceRDFa      = "rdfa"
+)
+
+//Item is a thing a page describes with structured data, like a schema.org Product.
+//Types and property names don't have the schema.org prefix. Property values are
+//strings, or *Item for nested things (maps once read back from the database)
+type Item struct {
+	Type       []string                 `json:"type,omitempty"`
+	ID         string                   `json:"id,omitempty"`
+	Properties map[string][]interface{} `json:"properties,omitempty"`
+	Source     string                   `json:"source"`
+}
+
+//vocabularies are the prefixes we take out of types and property names
+var vocabularies = []string{"http://schema.org/", "https://schema.org/", "schema:"}
+
+//syntax has the attributes Microdata or RDFa use, both nest items the same way
+type syntax struct {
+	source string
+	//scope is the attribute that starts an item, itemType the one with its types
+	scope    string
+	itemType string
+	ids      []string
+	property string
+}
+
+var microdata = syntax{source: SourceMicrodata, scope: "itemscope", itemType: "itemtype", ids: []string{"itemid"}, property: "itemprop"}
+
+var rdfa = syntax{source: SourceRDFa, scope: "typeof", itemType: "typeof", ids: []string{"resource", "about"}, property: "property"}
+
+//ExtractStructuredData gets the items a page describes with JSON-LD, Microdata and
+//basic RDFa, in that order. Urls are resolved against the page url, or the
+//document's <base href> if it has one
+func ExtractStructuredData(payload string, originalURL string) []Item {
+	base, err := url.Parse(originalURL)
+	if err != nil {
+		return nil
+	}
+	root, err := html.Parse(strings.NewReader(payload))
+	if err != nil {
+		log.Errorf("Error parsing %s, got: %v\n", originalURL, err)
+		return nil
+	}
+	if href, ok := findBase(root); ok {
+		if ref, err := url.Parse(href); err == nil {
+			base = base.ResolveReference(ref)
+		}
+	}
+	var jsonld, items, rdfaItems []Item
+	walk(root, func(n *html.Node) {
+		if n.DataAtom == atom.Script {
+			if scriptType, _ := nodeAttr(n, "type"); strings.EqualFold(scriptType, "application/ld+json") && n.FirstChild != nil {
+				jsonld = append(jsonld, readJSONLD(n.FirstChild.Data)...)
+			}
+			return
+		}
+		if microdata.isTopLevel(n) {
+			items = append(items, *microdata.item(n, base))
+		}
+		if rdfa.isTopLevel(n) {
+			rdfaItems = append(rdfaItems, *rdfa.item(n, base))
+		}
+	})
+	return append(append(jsonld, items...), rdfaItems...)
+}
+
+//add appends value to the property called name
+func (item *Item) add(name string, value interface{}) {
+	if item.Properties == nil {
+		item.Properties = make(map[string][]interface{})
+	}
+	name = shortName(name)
+	item.Properties[name] = append(item.Properties[name], value)
+}
+
+//shortName takes the schema.org prefix out of a type or property name
+func shortName(name string) string {
+	for _, prefix := range vocabularies {
+		if strings.HasPrefix(name, prefix) {
+			return name[len(prefix):]
+		}
+	}
+	return name
+}
+
+//isTopLevel tells us if n starts an item that is not the property of another one
+func (s syntax) isTopLevel(n *html.Node) bool {
+	_, scope := nodeAttr(n, s.scope)
+	_, property := nodeAttr(n, s.property)
+	return scope && !property
+}
+
+//item reads the item n starts
+func (s syntax) item(n *html.Node, base *url.URL) *Item {
+	item := &Item{Source: s.source}
+	types, _ := nodeAttr(n, s.itemType)
+	for _, t := range strings.Fields(types) {
+		item.Type = append(item.Type, shortName(t))
+	}
+	for _, key := range s.ids {
+		if id, _ := nodeAttr(n, key); id != "" {
+			item.ID = id
+			break
+		}
+	}
+	s.properties(n, item, base)
+	return item
+}
+
+//properties adds the properties under n to item, items nested in n have their own
+func (s syntax) properties(n *html.Node, item *Item, base *url.URL) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		_, scope := nodeAttr(c, s.scope)
+		if names, ok := nodeAttr(c, s.property); ok {
+			value := s.value(c, base)
+			for _, name := range strings.Fields(names) {
+				item.add(name, value)
+			}
+		}
+		if !scope {
+			s.properties(c, item, base)
+		}
+	}
+}
+
+//value is what the property n holds: a nested item, an attribute that
+//depends on the element, or its text
+func (s syntax) value(n *html.Node, base *url.URL) interface{} {
+	if _, ok := nodeAttr(n, s.scope); ok {
+		return s.item(n, base)
+	}
+	if content, ok := nodeAttr(n, "content"); ok {
+		return content
+	}
+	for _, key := range []string{"href", "src", "data", "resource"} {
+		if link, ok := nodeAttr(n, key); ok {
+			return resolveURL(base, link)
+		}
+	}
+	for _, key := range []string{"datetime", "value"} {
+		if value, ok := nodeAttr(n, key); ok {
+			return value
+		}
+	}
+	return textContent(n)
+}
+
+//readJSONLD reads the items in a <script type="application/ld+json"> block
+func readJSONLD(text string) []Item {
+	d := json.NewDecoder(strings.NewReader(text))
+	d.UseNumber()
+	var data interface{}
+	if err := d.Decode(&data); err != nil {
+		log.V(2).Infof("Invalid JSON-LD, got: %v\n", err)
+		return nil
+	}
+	var ret []Item
+	for _, node := range jsonldNodes(data) {
+		ret = append(ret, *jsonldItem(node))
+	}
+	return ret
+}
+
+//jsonldNodes lists the top level objects of a JSON-LD block, which can be an
+//object, an array of them or a @graph
+func jsonldNodes(data interface{}) []map[string]interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		var ret []map[string]interface{}
+		for _, element := range v {
+			ret = append(ret, jsonldNodes(element)...)
+		}
+		return ret
+	case map[string]interface{}:
+		if graph, ok := v["@graph"]; ok {
+			return jsonldNodes(graph)
+		}
+		return []map[string]interface{}{v}
+	}
+	return nil
+}
+
+func jsonldItem(node map[string]interface{}) *Item {
+	item := &Item{Source: SourceJSONLD}
+	for key, value := range node {
+		switch {
+		case key == "@type":
+			for _, t := range jsonldValues(value) {
+				if name, ok := t.(string); ok {
+					item.Type = append(item.Type, shortName(name))
+				}
+			}
+		case key == "@id":
+			item.ID, _ = value.(string)
+		case !strings.HasPrefix(key, "@"):
+			for _, v := range jsonldValues(value) {
+				item.add(key, v)
+			}
+		}
+	}
+	return item
+}
+
+//jsonldValues turns a JSON-LD value into strings and items, arrays give one value
+//per element. Numbers and booleans become strings too
+func jsonldValues(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []interface{}{v}
+	case []interface{}:
+		var ret []interface{}
+		for _, element := range v {
+			ret = append(ret, jsonldValues(element)...)
+		}
+		return ret
+	case map[string]interface{}:
+		if literal, ok := v["@value"]; ok {
+			return jsonldValues(literal)
+		}
+		return []interface{}{jsonldItem(v)}
+	}
+	return []interface{}{fmt.Sprint(value)}
+}
+
+//walk calls visit for every element under n, parents first
+func walk(n *html.Node, visit func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		visit(n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, visit)
+	}
+}
+
+//findBase gets the href of the first <base> of the document
+func findBase(n *html.Node) (string, bool) {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Base {
+		if href, ok := nodeAttr(n, "href"); ok {
+			return href, true
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href, ok := findBase(c); ok {
+			return href, true
+		}
+	}
+	return "", false
+}
+
+//nodeAttr returns the value of the named attribute of n
+func nodeAttr(n *html.Node, name string) (string, bool) {
+	for _, attribute := range n.Attr {
+		if attribute.Key == name {
+			return strings.TrimSpace(attribute.Val), true
+		}
+	}
+	return "", false
+}
+
+//textContent is the text under n, with its whitespace collapsed
+func textContent(n *html.Node) string {
+	var text []string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text = append(text, n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(strings.Join(text, " ")), " ")
+}
+
+//resolveURL resolves href against base and normalizes it, empty if it's not a valid url
+func resolveURL(base *url.URL, href string) string {
+	ref, err := url.Parse(href)
+	if err != nil || href == "" {
+		return ""
+	}
+	link, err := NormalizeURL(base.ResolveReference(ref).String())
+	if err != nil {
+		return ""
+	}
+	return link
+}
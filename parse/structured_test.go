@@ -0,0 +1,80 @@
+package parse
+
+import (
+	"testing"
+)
+
+var doc7 = `<html><head>
+<script type="application/ld+json">
+{"@context": "https://schema.org", "@graph": [
+  {"@type": "Article", "headline": "Owls at night", "datePublished": "2015-06-01",
+   "author": {"@type": "Person", "name": "Jane Owl"}},
+  {"@type": ["Organization"], "@id": "http://example.com/#org", "name": "Owl Co"}
+]}
+</script>
+<script type="application/ld+json">{not json</script>
+</head><body>
+<div itemscope itemtype="http://schema.org/Product">
+  <h1 itemprop="name">Owl  figurine</h1>
+  <img itemprop="image" src="/owl.jpg">
+  <div itemprop="offers" itemscope itemtype="http://schema.org/Offer">
+    <meta itemprop="priceCurrency" content="USD">
+    <span itemprop="price" content="19.99">$19.99</span>
+  </div>
+</div>
+<div vocab="http://schema.org/" typeof="Event">
+  <span property="name">Owl watching</span>
+  <time property="startDate" datetime="2015-07-01T20:00">July 1st</time>
+</div>
+</body></html>`
+
+func TestExtractStructuredData(t *testing.T) {
+	items := ExtractStructuredData(doc7, "http://example.com/shop/")
+	if len(items) != 4 {
+		t.Fatalf("Expected 2 JSON-LD items, a Microdata one and an RDFa one. It gave: %+v\n", items)
+	}
+	article := items[0]
+	if article.Source != SourceJSONLD || article.Type[0] != "Article" || article.Properties["datePublished"][0] != "2015-06-01" {
+		t.Errorf("Wrong JSON-LD article. It gave: %+v\n", article)
+	}
+	if author, ok := article.Properties["author"][0].(*Item); !ok || author.Type[0] != "Person" || author.Properties["name"][0] != "Jane Owl" {
+		t.Errorf("Nested JSON-LD objects should be items. It gave: %+v\n", article.Properties["author"])
+	}
+	if items[1].ID != "http://example.com/#org" || items[1].Type[0] != "Organization" {
+		t.Errorf("Wrong JSON-LD organization. It gave: %+v\n", items[1])
+	}
+
+	product := items[2]
+	if product.Source != SourceMicrodata || product.Type[0] != "Product" || product.Properties["name"][0] != "Owl figurine" {
+		t.Errorf("Wrong Microdata product. It gave: %+v\n", product)
+	}
+	if product.Properties["image"][0] != "http://example.com/owl.jpg" {
+		t.Errorf("Urls should be resolved. It gave: %+v\n", product.Properties["image"])
+	}
+	offer, ok := product.Properties["offers"][0].(*Item)
+	if !ok || offer.Properties["price"][0] != "19.99" || offer.Properties["priceCurrency"][0] != "USD" {
+		t.Errorf("Wrong Microdata offer. It gave: %+v\n", product.Properties["offers"])
+	}
+	if _, ok := product.Properties["price"]; ok {
+		t.Errorf("Properties of nested items should not go to their parent. It gave: %+v\n", product.Properties)
+	}
+
+	event := items[3]
+	if event.Source != SourceRDFa || event.Type[0] != "Event" || event.Properties["startDate"][0] != "2015-07-01T20:00" || event.Properties["name"][0] != "Owl watching" {
+		t.Errorf("Wrong RDFa event. It gave: %+v\n", event)
+	}
+}
+
+func TestJSONLDValues(t *testing.T) {
+	items := readJSONLD(`{"@type": "schema:Offer", "price": 19.5, "available": true, "name": {"@value": "Owl"}, "sku": null}`)
+	if len(items) != 1 || items[0].Type[0] != "Offer" {
+		t.Fatalf("Expected one offer. It gave: %+v\n", items)
+	}
+	properties := items[0].Properties
+	if properties["price"][0] != "19.5" || properties["available"][0] != "true" || properties["name"][0] != "Owl" {
+		t.Errorf("Values should be normalized to strings. It gave: %+v\n", properties)
+	}
+	if _, ok := properties["sku"]; ok {
+		t.Errorf("Null values should be left out. It gave: %+v\n", properties)
+	}
+}
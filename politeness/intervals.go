@@ -0,0 +1,50 @@
+package politeness
+
+import (
+	"sync"
+	"time"
+)
+
+//Intervals remembers the time between requests of each host for ttl,
+//so we don't look it up for every url we fetch
+type Intervals struct {
+	ttl    time.Duration
+	mu     sync.Mutex
+	hosts  map[string]cachedInterval
+	pruned time.Time
+}
+
+type cachedInterval struct {
+	interval time.Duration
+	expires  time.Time
+}
+
+//NewIntervals creates an Intervals keeping what it looks up for ttl
+func NewIntervals(ttl time.Duration) *Intervals {
+	return &Intervals{ttl: ttl, hosts: make(map[string]cachedInterval)}
+}
+
+//Get returns the interval of host, calling lookup when we don't know it or it expired
+func (i *Intervals) Get(host string, lookup func() time.Duration) time.Duration {
+	now := time.Now()
+	i.mu.Lock()
+	cached, ok := i.hosts[host]
+	i.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.interval
+	}
+	interval := lookup()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	//Hosts we stopped fetching from are forgotten once they expire
+	if now.Sub(i.pruned) >= i.ttl {
+		i.pruned = now
+		for h, c := range i.hosts {
+			if !now.Before(c.expires) {
+				delete(i.hosts, h)
+			}
+		}
+	}
+	i.hosts[host] = cachedInterval{interval: interval, expires: now.Add(i.ttl)}
+	return interval
+}
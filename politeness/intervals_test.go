@@ -0,0 +1,27 @@
+package politeness
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIntervalsCache(t *testing.T) {
+	i := NewIntervals(time.Hour)
+	lookups := 0
+	lookup := func() time.Duration {
+		lookups++
+		return 3 * time.Second
+	}
+	if got := i.Get("example.com", lookup); got != 3*time.Second {
+		t.Errorf("Expected the interval lookup gave. It gave: %v\n", got)
+	}
+	i.Get("example.com", lookup)
+	if lookups != 1 {
+		t.Errorf("Expected one lookup while the interval is cached. It gave: %d\n", lookups)
+	}
+	i.Get("other.com", lookup)
+	i.hosts["example.com"] = cachedInterval{interval: time.Second, expires: time.Now().Add(-time.Second)}
+	if got := i.Get("example.com", lookup); got != 3*time.Second || lookups != 3 {
+		t.Errorf("Expired intervals should be looked up again. It gave: %v after %d lookups\n", got, lookups)
+	}
+}
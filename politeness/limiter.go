@@ -0,0 +1,47 @@
+package politeness
+
+import (
+	"sync"
+)
+
+//Limiter caps how many requests to the same host one fetcher has in flight
+type Limiter struct {
+	perHost  int
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+//NewLimiter creates a Limiter allowing perHost requests to each host at the same time
+func NewLimiter(perHost int) *Limiter {
+	return &Limiter{perHost: perHost, inFlight: make(map[string]int)}
+}
+
+//TryAcquire takes a slot for host, telling us if there was one free.
+//Every successful call has to be followed by a Release
+func (l *Limiter) TryAcquire(host string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[host] >= l.perHost {
+		return false
+	}
+	l.inFlight[host]++
+	return true
+}
+
+//Free tells us if host has a slot free, without taking it
+func (l *Limiter) Free(host string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight[host] < l.perHost
+}
+
+//Release gives back a slot taken with TryAcquire
+func (l *Limiter) Release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[host] <= 1 {
+		delete(l.inFlight, host)
+		return
+	}
+	l.inFlight[host]--
+}
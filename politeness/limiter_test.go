@@ -0,0 +1,28 @@
+package politeness
+
+import (
+	"testing"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(2)
+	if !l.TryAcquire("example.com") || !l.TryAcquire("example.com") {
+		t.Fatalf("Expected two slots for example.com\n")
+	}
+	if l.TryAcquire("example.com") || l.Free("example.com") {
+		t.Errorf("A third request to example.com should wait\n")
+	}
+	if !l.TryAcquire("example.org") {
+		t.Errorf("Other hosts should not be affected\n")
+	}
+	l.Release("example.com")
+	if !l.TryAcquire("example.com") {
+		t.Errorf("Released slots should be free again\n")
+	}
+	l.Release("example.com")
+	l.Release("example.com")
+	l.Release("example.org")
+	if len(l.inFlight) != 0 {
+		t.Errorf("Idle hosts should be forgotten. It gave: %v\n", l.inFlight)
+	}
+}
@@ -0,0 +1,194 @@
+package politeness
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/nats-io/nats"
+)
+
+//Subject is where fetchers announce the time slots they claim for a host
+const Subject = "politeness"
+
+//claimSettle is how long we wait after announcing a claim before trusting it,
+//so claims from other fetchers for the same slot have time to arrive
+const claimSettle = 250 * time.Millisecond
+
+//pruneEvery is how often we forget the hosts nobody is waiting on
+const pruneEvery = time.Minute
+
+//claim is the message fetchers exchange. It says Owner will hit Host at At,
+//and nobody else should until At+Interval
+type claim struct {
+	Host     string        `json:"host"`
+	At       time.Time     `json:"at"`
+	Interval time.Duration `json:"interval"`
+	Owner    string        `json:"owner"`
+	lost     bool
+}
+
+type hostState struct {
+	next time.Time
+	//pending are our claims waiting to settle, one per worker fetching from the host
+	pending []*claim
+	//shared is set once another fetcher claims the host, only then do our claims need to settle
+	shared bool
+}
+
+//Scheduler makes sure we never hit a host more often than its interval allows,
+//across all fetcher processes connected to the same gnatsd
+type Scheduler struct {
+	id    string
+	nc    *nats.Conn
+	mu    sync.Mutex
+	hosts map[string]*hostState
+	//pruned is when we last forgot the hosts nobody is waiting on
+	pruned time.Time
+}
+
+//NewScheduler creates a Scheduler and starts listening to the claims of other fetchers.
+//id has to be unique per fetcher process
+func NewScheduler(nc *nats.Conn, id string) (*Scheduler, error) {
+	s := &Scheduler{
+		id:    id,
+		nc:    nc,
+		hosts: make(map[string]*hostState),
+	}
+	_, err := nc.Subscribe(Subject, func(msg *nats.Msg) {
+		var c claim
+		if err := json.Unmarshal(msg.Data, &c); err != nil {
+			log.Errorf("Invalid politeness claim %s, got: %v\n", string(msg.Data), err)
+			return
+		}
+		s.remoteClaim(&c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Wait blocks until we are allowed to fetch a page from host.
+//interval is the minimum time between two requests to host.
+//Once another fetcher claimed the host, we wait at least claimSettle for every slot,
+//before that two fetchers finding a host at the same time may both hit it once
+func (s *Scheduler) Wait(host string, interval time.Duration) {
+	for {
+		c, shared := s.reserve(host, interval)
+		wait := c.At.Sub(time.Now())
+		if shared && wait < claimSettle {
+			wait = claimSettle
+		}
+		log.V(3).Infof("Waiting %v before fetching from %s\n", wait, host)
+		time.Sleep(wait)
+		if s.confirm(c) {
+			return
+		}
+		log.V(3).Infof("Lost slot for %s to another fetcher, trying again\n", host)
+	}
+}
+
+//Ready tells us if we could hit host right now, as far as the claims we know of go
+func (s *Scheduler) Ready(host string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.hosts[host]
+	return !ok || !h.next.After(time.Now())
+}
+
+//reserve claims the next free slot for host, telling us if other fetchers claimed it too
+func (s *Scheduler) reserve(host string, interval time.Duration) (*claim, bool) {
+	s.mu.Lock()
+	at := time.Now()
+	s.prune(at)
+	h := s.host(host)
+	if h.next.After(at) {
+		at = h.next
+	}
+	h.next = at.Add(interval)
+	c := &claim{Host: host, At: at, Interval: interval, Owner: s.id}
+	h.pending = append(h.pending, c)
+	shared := h.shared
+	s.mu.Unlock()
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		log.Errorf("Error generating politeness claim, got: %v\n", err)
+		return c, shared
+	}
+	if err := s.nc.Publish(Subject, data); err != nil {
+		log.Errorf("Failed to publish politeness claim for %s, got: %v\n", host, err)
+	}
+	return c, shared
+}
+
+//confirm tells us if c is still ours and clears it
+func (s *Scheduler) confirm(c *claim) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h := s.host(c.Host)
+	for i, pending := range h.pending {
+		if pending == c {
+			h.pending = append(h.pending[:i], h.pending[i+1:]...)
+			break
+		}
+	}
+	return !c.lost
+}
+
+func (s *Scheduler) remoteClaim(c *claim) {
+	if c.Owner == s.id {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	h := s.host(c.Host)
+	h.shared = true
+	if end := c.At.Add(c.Interval); end.After(h.next) {
+		h.next = end
+	}
+	//When two fetchers claim overlapping slots, the one with the lowest id keeps it
+	for _, pending := range h.pending {
+		if overlaps(pending, c) && c.Owner < s.id {
+			pending.lost = true
+		}
+	}
+}
+
+func (s *Scheduler) host(host string) *hostState {
+	h, ok := s.hosts[host]
+	if !ok {
+		h = &hostState{}
+		s.hosts[host] = h
+	}
+	return h
+}
+
+//prune forgets the hosts whose slots are over and have no claims of ours waiting,
+//at most once every pruneEvery. Otherwise we would keep every host any fetcher ever hit
+func (s *Scheduler) prune(now time.Time) {
+	if now.Sub(s.pruned) < pruneEvery {
+		return
+	}
+	s.pruned = now
+	for host, h := range s.hosts {
+		if !h.next.After(now) && len(h.pending) == 0 {
+			delete(s.hosts, host)
+		}
+	}
+}
+
+func overlaps(a, b *claim) bool {
+	gap := a.At.Sub(b.At)
+	if gap < 0 {
+		gap = -gap
+	}
+	interval := a.Interval
+	if b.Interval > interval {
+		interval = b.Interval
+	}
+	return gap < interval
+}
@@ -0,0 +1,78 @@
+package politeness
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRemoteClaimPushesNextSlot(t *testing.T) {
+	s := &Scheduler{id: "b", hosts: make(map[string]*hostState)}
+	now := time.Now()
+	s.remoteClaim(&claim{Host: "example.com", At: now, Interval: 10 * time.Second, Owner: "a"})
+	if next := s.host("example.com").next; !next.Equal(now.Add(10 * time.Second)) {
+		t.Errorf("Expected next slot to be pushed by the remote claim. It gave: %v\n", next)
+	}
+	if !s.host("other.com").next.IsZero() {
+		t.Errorf("A claim for one host should not affect another one\n")
+	}
+	if s.Ready("example.com") || !s.Ready("other.com") {
+		t.Errorf("Only other.com should be ready\n")
+	}
+}
+
+func TestLowestOwnerWins(t *testing.T) {
+	now := time.Now()
+	s := &Scheduler{id: "b", hosts: make(map[string]*hostState)}
+	mine := &claim{Host: "example.com", At: now, Interval: 5 * time.Second, Owner: "b"}
+	s.host("example.com").pending = []*claim{mine}
+	s.remoteClaim(&claim{Host: "example.com", At: now.Add(time.Second), Interval: 5 * time.Second, Owner: "a"})
+	if s.confirm(mine) {
+		t.Errorf("Expected to lose the slot to a lower owner\n")
+	}
+
+	s = &Scheduler{id: "a", hosts: make(map[string]*hostState)}
+	mine = &claim{Host: "example.com", At: now, Interval: 5 * time.Second, Owner: "a"}
+	s.host("example.com").pending = []*claim{mine}
+	s.remoteClaim(&claim{Host: "example.com", At: now.Add(time.Second), Interval: 5 * time.Second, Owner: "b"})
+	if !s.confirm(mine) {
+		t.Errorf("Expected to keep the slot against a higher owner\n")
+	}
+}
+
+func TestNonOverlappingClaims(t *testing.T) {
+	now := time.Now()
+	a := &claim{At: now, Interval: 5 * time.Second}
+	b := &claim{At: now.Add(5 * time.Second), Interval: 5 * time.Second}
+	if overlaps(a, b) {
+		t.Errorf("Back to back claims should not overlap\n")
+	}
+}
+
+func TestPruneForgetsIdleHosts(t *testing.T) {
+	now := time.Now()
+	s := &Scheduler{id: "b", hosts: make(map[string]*hostState)}
+	s.host("idle.com").next = now.Add(-time.Second)
+	s.host("busy.com").next = now.Add(time.Second)
+	s.host("waiting.com").pending = []*claim{{Host: "waiting.com", At: now, Owner: "b"}}
+	s.prune(now)
+	if _, ok := s.hosts["idle.com"]; ok || len(s.hosts) != 2 {
+		t.Errorf("Only idle.com should be forgotten. It gave: %v\n", s.hosts)
+	}
+	s.host("idle.com")
+	s.prune(now.Add(time.Second))
+	if _, ok := s.hosts["idle.com"]; !ok {
+		t.Errorf("Expected to prune at most once every %v\n", pruneEvery)
+	}
+}
+
+func TestRemoteClaimSharesHost(t *testing.T) {
+	s := &Scheduler{id: "b", hosts: make(map[string]*hostState)}
+	if s.host("example.com").shared {
+		t.Errorf("A host nobody else claimed should not be shared\n")
+	}
+	s.remoteClaim(&claim{Host: "example.com", At: time.Now(), Interval: time.Second, Owner: "a"})
+	s.remoteClaim(&claim{Host: "other.com", At: time.Now(), Interval: time.Second, Owner: "b"})
+	if !s.host("example.com").shared || s.host("other.com").shared {
+		t.Errorf("Only example.com was claimed by another fetcher\n")
+	}
+}
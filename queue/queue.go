@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/parse"
+)
+
+//Version of the Message format we publish
+const Version = 1
+
+//Kinds of urls we fetch, pages have none
+const (
+	//KindSitemap is a sitemap.xml, we queue the urls it lists instead of storing it
+	KindSitemap = "sitemap"
+	//KindFeed is an RSS or Atom feed, we queue its entries and poll it for new ones
+	KindFeed = "feed"
+)
+
+//Priorities of the urls we fetch, higher ones are fetched first
+const (
+	//PriorityLow is for the later pages of listings, we get to them when there is nothing else
+	PriorityLow    = -10
+	PriorityNormal = 0
+	//PriorityHigh is for the sites submitted through the webapp
+	PriorityHigh = 10
+	//PriorityNow is for urls someone asked to crawl right away
+	PriorityNow = 100
+)
+
+//Message is what we publish to the fetch_url and extract_url queues
+type Message struct {
+	Version int `json:"v"`
+	//URL is the page to fetch, or the page that was fetched
+	URL string `json:"url,omitempty"`
+	//DocID is the document that holds the fetched page, only used on extract_url
+	DocID string `json:"doc_id,omitempty"`
+	//Referrer is the page we found URL on
+	Referrer string `json:"referrer,omitempty"`
+	//Depth is how many links away from Site we are
+	Depth int `json:"depth"`
+	//Site is the seed url submitted through the webapp that led us here
+	Site string `json:"site,omitempty"`
+	//Priority is one of the Priority constants, or in between them
+	Priority int `json:"priority,omitempty"`
+	//Retries is how many times we already tried to fetch URL
+	Retries int `json:"retries,omitempty"`
+	//Recrawl asks to fetch URL again even if we already have it
+	Recrawl bool `json:"recrawl,omitempty"`
+	//Kind is one of the Kind constants, empty for pages
+	Kind string `json:"kind,omitempty"`
+	//LastMod is when a sitemap or feed says the page last changed. If we fetched it
+	//before that, a recrawl fetches it again even if it's not due yet
+	LastMod *time.Time `json:"lastmod,omitempty"`
+	//ChangeFreq is how often a sitemap says the page changes, we revisit
+	//new pages that often until we know better
+	ChangeFreq string `json:"changefreq,omitempty"`
+}
+
+//Encode generates the payload to publish for m
+func Encode(m Message) ([]byte, error) {
+	m.Version = Version
+	return json.Marshal(m)
+}
+
+//DecodeFetch reads a fetch_url payload. Plain string payloads are taken as the url
+func DecodeFetch(data []byte) (Message, error) {
+	return decode(data, func(s string) Message { return Message{URL: s} })
+}
+
+//DecodeExtract reads an extract_url payload. Plain string payloads are taken as the doc id
+func DecodeExtract(data []byte) (Message, error) {
+	return decode(data, func(s string) Message { return Message{DocID: s} })
+}
+
+func decode(data []byte, plain func(string) Message) (Message, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return Message{}, fmt.Errorf("Empty message")
+	}
+	if trimmed[0] != '{' {
+		return plain(string(trimmed)), nil
+	}
+	var m Message
+	if err := json.Unmarshal(trimmed, &m); err != nil {
+		return m, fmt.Errorf("Invalid message %s, got: %v", string(data), err)
+	}
+	if m.Version > Version {
+		return m, fmt.Errorf("Unsupported message version %d", m.Version)
+	}
+	return m, nil
+}
+
+//Rank is what fetchers order urls by, the priority minus how deep the url is
+func (m Message) Rank() int {
+	return m.Priority - m.Depth
+}
+
+//Child is the message for a link found on the page of m.
+//Later pages of listings get a low priority, the rest a normal one
+func (m Message) Child(link string) Message {
+	priority := PriorityNormal
+	if parse.IsPagination(link) {
+		priority = PriorityLow
+	}
+	return Message{
+		URL:      link,
+		Referrer: m.URL,
+		Depth:    m.Depth + 1,
+		Site:     m.Site,
+		Priority: priority,
+	}
+}
+
+//SitemapPriority turns a sitemap <priority>, from 0.0 to 1.0 with 0.5 as the
+//default, into a priority between PriorityLow and PriorityHigh
+func SitemapPriority(p float64) int {
+	if p < 0 || p > 1 {
+		p = 0.5
+	}
+	return int(math.Round((p - 0.5) * 2 * PriorityHigh))
+}
@@ -0,0 +1,68 @@
+package queue
+
+import (
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	sent := Message{URL: "http://example.com/a", Referrer: "http://example.com/", Depth: 2, Site: "http://example.com", Retries: 1}
+	data, err := Encode(sent)
+	if err != nil {
+		t.Fatalf("Encode failed with: %v\n", err)
+	}
+	got, err := DecodeFetch(data)
+	if err != nil {
+		t.Fatalf("DecodeFetch failed with: %v\n", err)
+	}
+	sent.Version = Version
+	if got != sent {
+		t.Errorf("Expected %+v. It gave: %+v\n", sent, got)
+	}
+}
+
+func TestDecodePlainPayloads(t *testing.T) {
+	m, err := DecodeFetch([]byte("http://example.com/a"))
+	if err != nil || m.URL != "http://example.com/a" || m.Depth != 0 {
+		t.Errorf("Plain fetch payload wasn't read as a url. It gave: %+v, %v\n", m, err)
+	}
+	m, err = DecodeExtract([]byte("aHR0cDovL2V4YW1wbGUuY29tL2E="))
+	if err != nil || m.DocID != "aHR0cDovL2V4YW1wbGUuY29tL2E=" {
+		t.Errorf("Plain extract payload wasn't read as a doc id. It gave: %+v, %v\n", m, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := DecodeFetch([]byte("")); err == nil {
+		t.Errorf("Expected an error for an empty payload\n")
+	}
+	if _, err := DecodeFetch([]byte(`{"v":99,"url":"http://example.com"}`)); err == nil {
+		t.Errorf("Expected an error for a newer message version\n")
+	}
+}
+
+func TestChild(t *testing.T) {
+	parent := Message{URL: "http://example.com/", Depth: 1, Site: "http://example.com", Retries: 3}
+	child := parent.Child("http://example.com/b")
+	if child.Depth != 2 || child.Referrer != parent.URL || child.Site != parent.Site || child.Retries != 0 {
+		t.Errorf("Child didn't carry the expected metadata. It gave: %+v\n", child)
+	}
+}
+
+func TestPriorities(t *testing.T) {
+	seed := Message{URL: "http://example.com/", Priority: PriorityHigh}
+	if seed.Child("http://example.com/b").Priority != PriorityNormal {
+		t.Errorf("Children should not inherit the priority of the seed\n")
+	}
+	deep := seed.Child("http://example.com/blog?page=7")
+	if deep.Priority != PriorityLow || deep.Rank() != PriorityLow-1 {
+		t.Errorf("Later pages of listings should have a low priority. It gave: %+v\n", deep)
+	}
+	if seed.Rank() <= seed.Child("http://example.com/b").Rank() {
+		t.Errorf("Shallow urls should rank higher\n")
+	}
+	for p, expected := range map[float64]int{0: PriorityLow, 0.5: PriorityNormal, 1: PriorityHigh, 0.8: 6, 7: PriorityNormal} {
+		if got := SitemapPriority(p); got != expected {
+			t.Errorf("SitemapPriority(%v) should be %d. It gave: %d\n", p, expected, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package recrawl
+
+import (
+	"time"
+)
+
+//Policy bounds how often we revisit a page
+type Policy struct {
+	//Initial is the revisit interval of a page we just found
+	Initial time.Duration
+	Min     time.Duration
+	Max     time.Duration
+}
+
+//DefaultPolicy is used when the config doesn't say otherwise
+var DefaultPolicy = Policy{
+	Initial: 24 * time.Hour,
+	Min:     time.Hour,
+	Max:     30 * 24 * time.Hour,
+}
+
+//History is what we know about how often a page changes.
+//It's stored with the page, under "revisit"
+type History struct {
+	FirstFetched time.Time `json:"first_fetched"`
+	LastChanged  time.Time `json:"last_changed"`
+	//Checks is how many times we fetched the page, Changes how many of those it had changed
+	Checks  int `json:"checks"`
+	Changes int `json:"changes"`
+	//Interval is the current revisit interval, in seconds
+	Interval  int64     `json:"interval"`
+	NextVisit time.Time `json:"next_visit"`
+}
+
+//Record updates the history after fetching the page at now.
+//Pages that changed get visited twice as often, pages that didn't half as often,
+//always within the policy bounds
+func (h *History) Record(now time.Time, changed bool, policy Policy) {
+	interval := time.Duration(h.Interval) * time.Second
+	if h.Checks == 0 {
+		h.FirstFetched = now
+		interval = policy.Initial
+	} else if changed {
+		interval = interval / 2
+	} else {
+		interval = interval * 2
+	}
+	if interval < policy.Min {
+		interval = policy.Min
+	}
+	if interval > policy.Max {
+		interval = policy.Max
+	}
+
+	h.Checks++
+	if changed {
+		h.Changes++
+		h.LastChanged = now
+	}
+	h.Interval = int64(interval / time.Second)
+	h.NextVisit = now.Add(interval)
+}
@@ -0,0 +1,49 @@
+package recrawl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecordAdaptsInterval(t *testing.T) {
+	var h History
+	now := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	h.Record(now, true, DefaultPolicy)
+	if h.Interval != int64(DefaultPolicy.Initial/time.Second) || !h.NextVisit.Equal(now.Add(DefaultPolicy.Initial)) {
+		t.Errorf("First fetch should use the initial interval. It gave: %+v\n", h)
+	}
+
+	now = h.NextVisit
+	h.Record(now, true, DefaultPolicy)
+	if h.Interval != int64(12*time.Hour/time.Second) {
+		t.Errorf("A change should halve the interval. It gave: %d\n", h.Interval)
+	}
+
+	now = h.NextVisit
+	h.Record(now, false, DefaultPolicy)
+	h.Record(now, false, DefaultPolicy)
+	if h.Interval != int64(48*time.Hour/time.Second) {
+		t.Errorf("No changes should double the interval. It gave: %d\n", h.Interval)
+	}
+	if h.Checks != 4 || h.Changes != 2 {
+		t.Errorf("Wrong counters. It gave: %+v\n", h)
+	}
+}
+
+func TestRecordStaysWithinPolicy(t *testing.T) {
+	policy := Policy{Initial: 2 * time.Hour, Min: time.Hour, Max: 3 * time.Hour}
+	var h History
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		h.Record(now, true, policy)
+	}
+	if h.Interval != int64(time.Hour/time.Second) {
+		t.Errorf("Interval went below the minimum. It gave: %d\n", h.Interval)
+	}
+	for i := 0; i < 5; i++ {
+		h.Record(now, false, policy)
+	}
+	if h.Interval != int64(3*time.Hour/time.Second) {
+		t.Errorf("Interval went above the maximum. It gave: %d\n", h.Interval)
+	}
+}
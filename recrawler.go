@@ -0,0 +1,136 @@
+// +build recrawlerExec
+
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/config"
+	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/frontier"
+	"github.com/fmpwizard/owlcrawler/queue"
+	"github.com/fmpwizard/owlcrawler/store"
+	log "github.com/golang/glog"
+	"github.com/nats-io/nats"
+)
+
+//dueBatch is the most pages of each site we look at on each pass
+const dueBatch = 1000
+
+//feedBatch is the most feeds we send to the fetchers on each pass
+const feedBatch = 500
+
+var configLoader = config.Flags(flag.CommandLine)
+var cfg *config.Config
+var db store.Store
+
+//sent remembers when we last asked to fetch a page, so we don't ask again
+//while the fetchers are still working on it
+var sent = make(map[string]time.Time)
+
+//schedule picks the due pages to send to the fetchers, at most budget per site,
+//leaving out the ones we sent less than resendAfter ago
+func schedule(due []couchdb.DuePage, budget int, resendAfter time.Duration, now time.Time) []couchdb.DuePage {
+	perSite := make(map[string]int)
+	var ret []couchdb.DuePage
+	for _, page := range due {
+		if last, ok := sent[page.ID]; ok && now.Sub(last) < resendAfter {
+			continue
+		}
+		if perSite[page.Site] >= budget {
+			continue
+		}
+		perSite[page.Site]++
+		ret = append(ret, page)
+	}
+	return ret
+}
+
+func recrawl(urlFrontier *frontier.Frontier) {
+	now := time.Now().UTC()
+	sites, err := db.PageSites()
+	if err != nil {
+		log.Errorf("Error getting the sites to revisit, got: %v\n", err)
+		return
+	}
+	for id, last := range sent {
+		if now.Sub(last) >= cfg.Recrawl.MinInterval.Duration {
+			delete(sent, id)
+		}
+	}
+	//Each site gets its own query, so one with many late pages doesn't hide the others
+	var due, pages []couchdb.DuePage
+	for _, site := range sites {
+		siteDue, err := db.DuePages(site, now, dueBatch)
+		if err != nil {
+			log.Errorf("Error getting pages of %q due for a revisit, got: %v\n", site, err)
+			continue
+		}
+		due = append(due, siteDue...)
+		pages = append(pages, schedule(siteDue, cfg.Recrawl.SiteBudget, cfg.Recrawl.MinInterval.Duration, now)...)
+	}
+	for _, page := range pages {
+		err := urlFrontier.Push(queue.Message{URL: page.URL, Site: page.Site, Depth: page.Depth, Recrawl: true})
+		if err != nil {
+			log.Errorf("Failed to push %s to fetch queue, got: %v\n", page.URL, err)
+			continue
+		}
+		sent[page.ID] = now
+	}
+	log.V(2).Infof("Sent %d of %d due pages to the fetchers\n", len(pages), len(due))
+}
+
+//pollFeeds sends the feeds due for a new read to the fetchers, ahead of the pages due for a revisit
+func pollFeeds(urlFrontier *frontier.Frontier) {
+	due, err := db.DueFeeds(time.Now().UTC(), feedBatch)
+	if err != nil {
+		log.Errorf("Error getting feeds due for a new read, got: %v\n", err)
+		return
+	}
+	for _, feed := range due {
+		err := urlFrontier.Push(queue.Message{URL: feed.URL, Site: feed.Site, Priority: queue.PriorityHigh, Kind: queue.KindFeed})
+		if err != nil {
+			log.Errorf("Failed to push feed %s to fetch queue, got: %v\n", feed.URL, err)
+		}
+	}
+	log.V(2).Infof("Sent %d feeds to the fetchers\n", len(due))
+}
+
+func main() {
+	flag.Parse()
+	log.V(2).Infoln("Starting Recrawler")
+	var err error
+	cfg, err = configLoader.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration, got: %v\n", err)
+	}
+	db, err = store.Open(cfg)
+	if err != nil {
+		log.Fatalf("Could not open %s store, got: %v\n", cfg.Store, err)
+	}
+	nc, err := nats.Connect(cfg.Gnatsd.URL)
+	if err != nil {
+		log.Fatalf("Error connecting to gnatsd, got: %v\n", err)
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	urlFrontier := frontier.New(db, nc, cfg.Frontier.AckTimeout.Duration, cfg.Frontier.MaxDeliveries)
+	for {
+		pollFeeds(urlFrontier)
+		recrawl(urlFrontier)
+		select {
+		case <-stop:
+			log.Infoln("Shutting down")
+			if err := nc.FlushTimeout(cfg.ShutdownTimeout.Duration); err != nil {
+				log.Errorf("Error flushing messages to gnatsd, got: %v\n", err)
+			}
+			nc.Close()
+			return
+		case <-time.After(cfg.Recrawl.Every.Duration):
+		}
+	}
+}
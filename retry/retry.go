@@ -0,0 +1,110 @@
+package retry
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//Policy says how many times we try to fetch a url and how long we wait in between
+type Policy struct {
+	//MaxAttempts is how many times we try before giving up, the first fetch included
+	MaxAttempts int
+	//Base is the wait before the first retry, it doubles on each attempt up to Max
+	Base time.Duration
+	Max  time.Duration
+}
+
+//DefaultPolicy is used when the config doesn't say otherwise
+var DefaultPolicy = Policy{
+	MaxAttempts: 5,
+	Base:        30 * time.Second,
+	Max:         time.Hour,
+}
+
+//Exhausted tells us if we already tried attempts times and should give up
+func (p Policy) Exhausted(attempts int) bool {
+	return attempts >= p.MaxAttempts
+}
+
+//Backoff is how long to wait after the given failed attempt, 1 being the first fetch.
+//The wait doubles on each attempt, and a random half of it is jitter so
+//fetchers that failed together don't retry together
+func (p Policy) Backoff(attempt int) time.Duration {
+	wait := p.Base
+	for i := 1; i < attempt && wait < p.Max; i++ {
+		wait *= 2
+	}
+	if wait > p.Max {
+		wait = p.Max
+	}
+	half := wait / 2
+	if half <= 0 {
+		return wait
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+//TransientStatus tells us if a response with this status code may work if we try again
+func TransientStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+//TransientError tells us if a failed request may work if we try again:
+//timeouts, dropped connections and name lookups that didn't get an answer
+func TransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) && urlErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+//RetryAfter reads a Retry-After header, given either in seconds or as an http date
+func RetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	when, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if wait := when.Sub(now); wait > 0 {
+		return wait, true
+	}
+	return 0, true
+}
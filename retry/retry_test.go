@@ -0,0 +1,83 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := Policy{MaxAttempts: 5, Base: 10 * time.Second, Max: time.Minute}
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for i, max := range expected {
+		for j := 0; j < 20; j++ {
+			wait := p.Backoff(i + 1)
+			if wait < max/2 || wait > max {
+				t.Errorf("Attempt %d should wait between %v and %v. It gave: %v\n", i+1, max/2, max, wait)
+			}
+		}
+	}
+	if p.Exhausted(4) || !p.Exhausted(5) {
+		t.Errorf("We should give up after 5 attempts\n")
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestTransientError(t *testing.T) {
+	transient := []error{
+		&url.Error{Op: "Get", URL: "http://example.com", Err: timeoutError{}},
+		&url.Error{Op: "Get", URL: "http://example.com", Err: &net.OpError{Op: "read", Err: syscall.ECONNRESET}},
+		fmt.Errorf("reading body: %w", syscall.ECONNREFUSED),
+		&net.DNSError{Err: "server misbehaving", Name: "example.com"},
+	}
+	for _, err := range transient {
+		if !TransientError(err) {
+			t.Errorf("%v should be transient\n", err)
+		}
+	}
+	permanent := []error{
+		&url.Error{Op: "Get", URL: "ftp://example.com", Err: errors.New("unsupported protocol scheme")},
+		&net.DNSError{Err: "no such host", Name: "does-not-exist.example", IsNotFound: true},
+		nil,
+	}
+	for _, err := range permanent {
+		if TransientError(err) {
+			t.Errorf("%v should not be transient\n", err)
+		}
+	}
+}
+
+func TestTransientStatus(t *testing.T) {
+	for _, code := range []int{429, 500, 503} {
+		if !TransientStatus(code) {
+			t.Errorf("%d should be transient\n", code)
+		}
+	}
+	for _, code := range []int{200, 301, 404, 501} {
+		if TransientStatus(code) {
+			t.Errorf("%d should not be transient\n", code)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC)
+	if wait, ok := RetryAfter("120", now); !ok || wait != 2*time.Minute {
+		t.Errorf("Expected 2m. It gave: %v, %v\n", wait, ok)
+	}
+	if wait, ok := RetryAfter("Mon, 01 Jun 2015 12:05:00 GMT", now); !ok || wait != 5*time.Minute {
+		t.Errorf("Expected 5m. It gave: %v, %v\n", wait, ok)
+	}
+	if _, ok := RetryAfter("soon", now); ok {
+		t.Errorf("Invalid values should be ignored\n")
+	}
+}
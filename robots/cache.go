@@ -0,0 +1,127 @@
+package robots
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "github.com/golang/glog"
+)
+
+//ErrUnavailable is returned when we could not get a host's robots.txt because of
+//a network error or a 5xx response. The url should be tried again later.
+var ErrUnavailable = errors.New("robots.txt unavailable.")
+
+//unavailableTTL is how long we remember a robots.txt was unavailable, so the urls of
+//a host whose robots.txt is broken don't all download it again
+const unavailableTTL = 5 * time.Minute
+
+//maxRobotsSize is how much of a robots.txt file we read, per RFC 9309 at least 500KiB
+const maxRobotsSize = 512 * 1024
+
+//Cache downloads and keeps robots.txt rules per host
+type Cache struct {
+	client    *http.Client
+	userAgent string
+	agentName string
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]*entry
+}
+
+type entry struct {
+	rules *Rules
+	//err is ErrUnavailable when we could not get the robots.txt
+	err     error
+	expires time.Time
+}
+
+//NewCache creates a Cache. agentName is the token we look for in User-agent lines,
+//userAgent is the full header we send when downloading robots.txt
+func NewCache(client *http.Client, agentName, userAgent string, ttl time.Duration) *Cache {
+	return &Cache{
+		client:    client,
+		userAgent: userAgent,
+		agentName: agentName,
+		ttl:       ttl,
+		entries:   make(map[string]*entry),
+	}
+}
+
+//Get returns the rules that apply to the host of the given url
+func (c *Cache) Get(target *url.URL) (*Rules, error) {
+	key := target.Scheme + "://" + target.Host
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.rules, e.err
+	}
+
+	rules, err := c.download(key + "/robots.txt")
+	if err == ErrUnavailable {
+		c.mu.Lock()
+		c.entries[key] = &entry{err: err, expires: time.Now().Add(unavailableTTL)}
+		c.mu.Unlock()
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.entries[key] = &entry{rules: rules, expires: time.Now().Add(c.ttl)}
+	c.mu.Unlock()
+	return rules, nil
+}
+
+//Check tells us if the url may be fetched, and if not, why
+func (c *Cache) Check(rawurl string) (allowed bool, reason string, err error) {
+	target, err := url.Parse(rawurl)
+	if err != nil {
+		return false, "invalid url", err
+	}
+	rules, err := c.Get(target)
+	if err != nil {
+		return false, "robots.txt unavailable", err
+	}
+	if !rules.Allowed(target.RequestURI()) {
+		return false, "disallowed by robots.txt", nil
+	}
+	return true, "", nil
+}
+
+func (c *Cache) download(robotsURL string) (*Rules, error) {
+	req, err := http.NewRequest("GET", robotsURL, nil)
+	if err != nil {
+		log.Errorf("Error parsing url: %s, got: %v\n", robotsURL, err)
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		log.Errorf("Error while fetching %s, got error: %v\n", robotsURL, err)
+		return nil, ErrUnavailable
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+		if err != nil {
+			log.Errorf("Error while reading %s, got error: %v\n", robotsURL, err)
+			return nil, ErrUnavailable
+		}
+		log.V(3).Infof("Got robots.txt from %s\n", robotsURL)
+		return Parse(string(body), c.agentName), nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		log.V(3).Infof("No robots.txt at %s (status %d), allowing all\n", robotsURL, resp.StatusCode)
+		return AllowAll, nil
+	default:
+		log.Errorf("Error fetching %s. Status Code was: %d\n", robotsURL, resp.StatusCode)
+		return nil, ErrUnavailable
+	}
+}
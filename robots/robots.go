@@ -0,0 +1,160 @@
+package robots
+
+import (
+	"bufio"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Rules holds the robots.txt directives that apply to one user agent
+type Rules struct {
+	rules      []rule
+	CrawlDelay time.Duration
+	Sitemaps   []string
+}
+
+type rule struct {
+	allow   bool
+	pattern string
+}
+
+type group struct {
+	agents     []string
+	rules      []rule
+	crawlDelay time.Duration
+}
+
+//AllowAll is used when a site has no robots.txt
+var AllowAll = &Rules{}
+
+//Parse reads a robots.txt file and returns the rules for the given user agent.
+//The most specific group wins, falling back to the * group.
+func Parse(content string, userAgent string) *Rules {
+	var groups []*group
+	var current *group
+	var sitemaps []string
+	lastWasAgent := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:idx]))
+		value := strings.TrimSpace(line[idx+1:])
+
+		switch key {
+		case "user-agent":
+			if !lastWasAgent || current == nil {
+				current = &group{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			lastWasAgent = true
+			continue
+		case "allow", "disallow":
+			if current != nil && value != "" {
+				current.rules = append(current.rules, rule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if current != nil {
+				if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+					current.crawlDelay = time.Duration(secs * float64(time.Second))
+				}
+			}
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		}
+		lastWasAgent = false
+	}
+
+	ret := &Rules{Sitemaps: sitemaps}
+	if g := matchGroup(groups, strings.ToLower(userAgent)); g != nil {
+		ret.rules = g.rules
+		ret.CrawlDelay = g.crawlDelay
+	}
+	return ret
+}
+
+//matchGroup picks the group of our product token, or the * group when there is none.
+//Per RFC 9309 tokens are compared case insensitively and as a whole, so a group
+//for "owl" is not ours. Versions like OwlCrawler/1.0 are left out of the comparison
+func matchGroup(groups []*group, userAgent string) *group {
+	token := productToken(userAgent)
+	var wildcard *group
+	for _, g := range groups {
+		for _, agent := range g.agents {
+			switch {
+			case agent == "*":
+				if wildcard == nil {
+					wildcard = g
+				}
+			case agent != "" && productToken(agent) == token:
+				return g
+			}
+		}
+	}
+	return wildcard
+}
+
+//productToken is the name at the start of a user agent, before its version or comments
+func productToken(agent string) string {
+	if idx := strings.IndexAny(agent, "/ \t("); idx >= 0 {
+		agent = agent[:idx]
+	}
+	return agent
+}
+
+//Allowed tells us if path (including the query string) may be fetched.
+//The longest matching rule wins, Allow wins ties.
+func (r *Rules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+	allowed := true
+	matchLen := -1
+	for _, ru := range r.rules {
+		if !match(ru.pattern, path) {
+			continue
+		}
+		if len(ru.pattern) > matchLen || (len(ru.pattern) == matchLen && ru.allow) {
+			allowed = ru.allow
+			matchLen = len(ru.pattern)
+		}
+	}
+	return allowed
+}
+
+//match implements the * and $ wildcards of robots.txt patterns
+func match(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+	for _, part := range parts[1:] {
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	if anchored {
+		last := parts[len(parts)-1]
+		if len(parts) > 1 {
+			return strings.HasSuffix(path, last)
+		}
+		return pos == len(path)
+	}
+	return true
+}
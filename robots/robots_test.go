@@ -0,0 +1,136 @@
+package robots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var robots1 = `
+# sample robots.txt
+User-agent: *
+Disallow: /private/
+Disallow: /*.pdf$
+Allow: /private/public.html
+
+User-agent: OwlCrawler
+User-agent: OtherBot
+Disallow: /no-owls/
+Allow: /no-owls/except-this
+Disallow: /search?*q=
+Crawl-delay: 2.5
+
+Sitemap: http://example.com/sitemap.xml
+`
+
+func TestParseSpecificGroup(t *testing.T) {
+	rules := Parse(robots1, "OwlCrawler")
+	if rules.CrawlDelay != 2500*time.Millisecond {
+		t.Errorf("Wrong crawl delay. It gave: %v\n", rules.CrawlDelay)
+	}
+	if len(rules.Sitemaps) != 1 {
+		t.Errorf("Expected one sitemap. It gave: %+v\n", rules.Sitemaps)
+	}
+	cases := map[string]bool{
+		"/":                      true,
+		"/private/":              true,
+		"/no-owls/":              false,
+		"/no-owls/page.html":     false,
+		"/no-owls/except-this":   true,
+		"/search?page=1&q=hello": false,
+		"/search?page=1":         true,
+	}
+	for path, expected := range cases {
+		if rules.Allowed(path) != expected {
+			t.Errorf("Allowed(%s) should be %t\n", path, expected)
+		}
+	}
+}
+
+func TestParseWildcardGroup(t *testing.T) {
+	rules := Parse(robots1, "SomeBot")
+	cases := map[string]bool{
+		"/":                    true,
+		"/private/secret.html": false,
+		"/private/public.html": true,
+		"/docs/file.pdf":       false,
+		"/docs/file.pdf?x=1":   true,
+		"/no-owls/":            true,
+	}
+	for path, expected := range cases {
+		if rules.Allowed(path) != expected {
+			t.Errorf("Allowed(%s) should be %t\n", path, expected)
+		}
+	}
+}
+
+func TestParseMatchesWholeProductToken(t *testing.T) {
+	content := `
+User-agent:
+Disallow: /empty/
+
+User-agent: owl
+User-agent: Crawler
+Disallow: /partial/
+
+User-agent: *
+Disallow: /everyone/
+
+User-agent: owlcrawler/2.1
+Disallow: /owls/
+`
+	rules := Parse(content, "OwlCrawler")
+	cases := map[string]bool{
+		"/empty/":    true,
+		"/partial/":  true,
+		"/everyone/": true,
+		"/owls/":     false,
+	}
+	for path, expected := range cases {
+		if rules.Allowed(path) != expected {
+			t.Errorf("Allowed(%s) should be %t\n", path, expected)
+		}
+	}
+	rules = Parse(content, "SomeBot")
+	if rules.Allowed("/everyone/") || !rules.Allowed("/empty/") {
+		t.Errorf("An empty user agent should not take the place of *\n")
+	}
+}
+
+func TestCacheStatusCodes(t *testing.T) {
+	status := http.StatusOK
+	hits := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(status)
+		w.Write([]byte("User-agent: *\nDisallow: /\n"))
+	}))
+	defer ts.Close()
+
+	cache := NewCache(&http.Client{}, "OwlCrawler", "OwlCrawler", time.Hour)
+	allowed, _, err := cache.Check(ts.URL + "/page.html")
+	if err != nil || allowed {
+		t.Errorf("Expected page to be disallowed. It gave: %t, %v\n", allowed, err)
+	}
+	cache.Check(ts.URL + "/other.html")
+	if hits != 1 {
+		t.Errorf("Expected robots.txt to be cached. It was fetched %d times\n", hits)
+	}
+
+	status = http.StatusNotFound
+	cache = NewCache(&http.Client{}, "OwlCrawler", "OwlCrawler", time.Hour)
+	if allowed, _, _ := cache.Check(ts.URL + "/page.html"); !allowed {
+		t.Errorf("A missing robots.txt should allow everything\n")
+	}
+
+	status = http.StatusServiceUnavailable
+	cache = NewCache(&http.Client{}, "OwlCrawler", "OwlCrawler", time.Hour)
+	hits = 0
+	if _, _, err := cache.Check(ts.URL + "/page.html"); err != ErrUnavailable {
+		t.Errorf("Expected ErrUnavailable. It gave: %v\n", err)
+	}
+	if _, _, err := cache.Check(ts.URL + "/other.html"); err != ErrUnavailable || hits != 1 {
+		t.Errorf("Expected an unavailable robots.txt to be remembered. It was fetched %d times\n", hits)
+	}
+}
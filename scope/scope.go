@@ -0,0 +1,131 @@
+package scope
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+//Rules limit which links found while crawling a site get fetched.
+//A link needs to pass the host rules, match one of Include (if any),
+//not match any of Exclude and be at most MaxDepth links away from the seed
+type Rules struct {
+	//SameHost allows links to the host of the seed url
+	SameHost bool `json:"same_host,omitempty"`
+	//SameDomain allows links to any host under the seed's registered domain,
+	//e.g. blog.example.com for www.example.com
+	SameDomain bool `json:"same_domain,omitempty"`
+	//AllowedHosts are other hosts we can follow links to
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	//Include and Exclude are regular expressions matched against the full url
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	//MaxDepth is how many links away from the seed we go, 0 means no limit
+	MaxDepth int `json:"max_depth,omitempty"`
+}
+
+//DefaultRules apply to sites submitted without scope rules
+var DefaultRules = Rules{SameHost: true}
+
+//Scope is a compiled set of Rules for one seed url
+type Scope struct {
+	rules      Rules
+	seedHost   string
+	seedDomain string
+	include    []*regexp.Regexp
+	exclude    []*regexp.Regexp
+}
+
+//Compile checks the rules and prepares them to be used against links of the given seed url
+func (r Rules) Compile(seed string) (*Scope, error) {
+	seedURL, err := url.Parse(seed)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid seed url %s, got: %v", seed, err)
+	}
+	s := &Scope{
+		rules:      r,
+		seedHost:   strings.ToLower(seedURL.Hostname()),
+		seedDomain: registeredDomain(seedURL.Hostname()),
+	}
+	for _, expr := range r.Include {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid include rule %s, got: %v", expr, err)
+		}
+		s.include = append(s.include, re)
+	}
+	for _, expr := range r.Exclude {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid exclude rule %s, got: %v", expr, err)
+		}
+		s.exclude = append(s.exclude, re)
+	}
+	return s, nil
+}
+
+//Allows tells us if link, found depth links away from the seed, should be fetched.
+//When it shouldn't, reason says why
+func (s *Scope) Allows(link string, depth int) (allowed bool, reason string) {
+	target, err := url.Parse(link)
+	if err != nil {
+		return false, "invalid url"
+	}
+	if s.rules.MaxDepth > 0 && depth > s.rules.MaxDepth {
+		return false, "too deep"
+	}
+	if !s.allowsHost(strings.ToLower(target.Hostname())) {
+		return false, "host out of scope"
+	}
+	if len(s.include) > 0 && !matchesAny(s.include, link) {
+		return false, "not included"
+	}
+	if matchesAny(s.exclude, link) {
+		return false, "excluded"
+	}
+	return true, ""
+}
+
+//LimitsHosts tells us if r has a host rule, without one we follow links to any host
+func (r Rules) LimitsHosts() bool {
+	return r.SameHost || r.SameDomain || len(r.AllowedHosts) > 0
+}
+
+func (s *Scope) allowsHost(host string) bool {
+	r := s.rules
+	if !r.LimitsHosts() {
+		return true
+	}
+	if r.SameHost && host == s.seedHost {
+		return true
+	}
+	if r.SameDomain && s.seedDomain != "" && registeredDomain(host) == s.seedDomain {
+		return true
+	}
+	for _, allowed := range r.AllowedHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+func registeredDomain(host string) string {
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	return domain
+}
+
+func matchesAny(exprs []*regexp.Regexp, link string) bool {
+	for _, re := range exprs {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
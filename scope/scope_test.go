@@ -0,0 +1,93 @@
+package scope
+
+import (
+	"testing"
+
+	"github.com/fmpwizard/owlcrawler/parse"
+)
+
+func TestDefaultRulesStayOnHost(t *testing.T) {
+	s, err := DefaultRules.Compile("http://www.example.com/")
+	if err != nil {
+		t.Fatalf("Compile failed with: %v\n", err)
+	}
+	if ok, _ := s.Allows("http://www.example.com/page.html", 1); !ok {
+		t.Errorf("Same host link should be allowed\n")
+	}
+	if ok, reason := s.Allows("http://other.com/page.html", 1); ok || reason != "host out of scope" {
+		t.Errorf("Other host link should not be allowed. It gave: %t, %s\n", ok, reason)
+	}
+}
+
+func TestSameDomainAndAllowedHosts(t *testing.T) {
+	rules := Rules{SameDomain: true, AllowedHosts: []string{"cdn.example.net"}}
+	s, _ := rules.Compile("http://www.example.co.uk/")
+	cases := map[string]bool{
+		"http://blog.example.co.uk/post": true,
+		"http://example.co.uk/":          true,
+		"http://other.co.uk/":            false,
+		"http://CDN.example.net/file":    true,
+	}
+	for link, expected := range cases {
+		if ok, _ := s.Allows(link, 1); ok != expected {
+			t.Errorf("Allows(%s) should be %t\n", link, expected)
+		}
+	}
+}
+
+func TestIncludeExcludeAndDepth(t *testing.T) {
+	rules := Rules{SameHost: true, Include: []string{"/docs/"}, Exclude: []string{`\.pdf$`}, MaxDepth: 2}
+	s, _ := rules.Compile("http://example.com/docs/")
+	if ok, _ := s.Allows("http://example.com/docs/a.html", 2); !ok {
+		t.Errorf("Included link should be allowed\n")
+	}
+	if ok, reason := s.Allows("http://example.com/blog/a.html", 1); ok || reason != "not included" {
+		t.Errorf("Link not matching include should not be allowed. It gave: %s\n", reason)
+	}
+	if ok, reason := s.Allows("http://example.com/docs/a.pdf", 1); ok || reason != "excluded" {
+		t.Errorf("Excluded link should not be allowed. It gave: %s\n", reason)
+	}
+	if ok, reason := s.Allows("http://example.com/docs/b.html", 3); ok || reason != "too deep" {
+		t.Errorf("Link past max depth should not be allowed. It gave: %s\n", reason)
+	}
+}
+
+func TestCompileInvalidRegexp(t *testing.T) {
+	rules := Rules{Include: []string{"("}}
+	if _, err := rules.Compile("http://example.com/"); err == nil {
+		t.Errorf("Expected an error for an invalid regular expression\n")
+	}
+}
+
+func TestSameDomainThroughExtractLinks(t *testing.T) {
+	s, _ := Rules{SameDomain: true}.Compile("http://www.example.com/")
+	page := `<html><body>
+<a href="http://blog.example.com/post.html">Blog</a>
+<a href="https://shop.example.com/">Shop</a>
+<a href="http://www.other.org/">Other</a>
+<a href="/about.html">About</a>
+</body></html>`
+	inScope := func(link string) bool {
+		ok, _ := s.Allows(link, 1)
+		return ok
+	}
+	toFetch, _ := parse.ExtractLinks(page, "http://www.example.com/", inScope)
+	expected := []string{"http://blog.example.com/post.html", "https://shop.example.com/", "http://www.example.com/about.html"}
+	if len(toFetch.URL) != len(expected) {
+		t.Fatalf("Expected the links under example.com. It gave: %+v\n", toFetch.URL)
+	}
+	for i, u := range expected {
+		if toFetch.URL[i] != u {
+			t.Errorf("Expected %s. It gave: %s\n", u, toFetch.URL[i])
+		}
+	}
+}
+
+func TestLimitsHosts(t *testing.T) {
+	if (Rules{Include: []string{"/docs/"}}).LimitsHosts() {
+		t.Errorf("Rules without host rules should not limit hosts\n")
+	}
+	if !DefaultRules.LimitsHosts() || !(Rules{AllowedHosts: []string{"cdn.example.com"}}).LimitsHosts() {
+		t.Errorf("Host rules should limit hosts\n")
+	}
+}
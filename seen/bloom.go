@@ -0,0 +1,113 @@
+package seen
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+//Bloom is a scalable Bloom filter. It tells us for sure when it never saw a url,
+//and wrongly says it did for about ErrorRate of the urls it didn't see.
+//When a layer is full we add one twice as big with half its error rate,
+//so the overall error rate stays under ErrorRate however many urls we add
+type Bloom struct {
+	Layers    []*layer
+	Capacity  int
+	ErrorRate float64
+}
+
+//layer is a plain Bloom filter holding up to Capacity urls
+type layer struct {
+	Bits     []uint64
+	Hashes   int
+	Capacity int
+	Count    int
+}
+
+//NewBloom creates an empty filter. Its first layer holds capacity urls
+func NewBloom(capacity int, errorRate float64) *Bloom {
+	b := &Bloom{Capacity: capacity, ErrorRate: errorRate}
+	b.grow()
+	return b
+}
+
+func newLayer(capacity int, errorRate float64) *layer {
+	n := float64(capacity)
+	bits := math.Ceil(-n * math.Log(errorRate) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Ceil(bits / n * math.Ln2))
+	return &layer{
+		Bits:     make([]uint64, (int(bits)+63)/64),
+		Hashes:   hashes,
+		Capacity: capacity,
+	}
+}
+
+func (b *Bloom) grow() {
+	i := len(b.Layers)
+	capacity := b.Capacity << uint(i)
+	errorRate := b.ErrorRate / math.Pow(2, float64(i+1))
+	b.Layers = append(b.Layers, newLayer(capacity, errorRate))
+}
+
+//Add records url
+func (b *Bloom) Add(url string) {
+	if b.Test(url) {
+		return
+	}
+	last := b.Layers[len(b.Layers)-1]
+	if last.Count >= last.Capacity {
+		b.grow()
+		last = b.Layers[len(b.Layers)-1]
+	}
+	h1, h2 := hashes(url)
+	last.add(h1, h2)
+}
+
+//Test tells us if we may have seen url
+func (b *Bloom) Test(url string) bool {
+	h1, h2 := hashes(url)
+	for _, l := range b.Layers {
+		if l.test(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+//Count is about how many urls we added, the ones it wrongly thought it saw are not counted
+func (b *Bloom) Count() int {
+	count := 0
+	for _, l := range b.Layers {
+		count += l.Count
+	}
+	return count
+}
+
+func (l *layer) add(h1, h2 uint64) {
+	size := uint64(len(l.Bits)) * 64
+	for i := 0; i < l.Hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		l.Bits[bit/64] |= 1 << (bit % 64)
+	}
+	l.Count++
+}
+
+func (l *layer) test(h1, h2 uint64) bool {
+	size := uint64(len(l.Bits)) * 64
+	for i := 0; i < l.Hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		if l.Bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+//hashes gives the two hashes of url every bit position is derived from.
+//They have to be the same in every process, so we can share filters
+func hashes(url string) (uint64, uint64) {
+	a := fnv.New64a()
+	a.Write([]byte(url))
+	b := fnv.New64()
+	b.Write([]byte(url))
+	return a.Sum64(), b.Sum64() | 1
+}
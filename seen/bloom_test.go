@@ -0,0 +1,40 @@
+package seen
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBloomNeverForgets(t *testing.T) {
+	b := NewBloom(100, 0.01)
+	for i := 0; i < 1000; i++ {
+		b.Add(fmt.Sprintf("http://example.com/%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if url := fmt.Sprintf("http://example.com/%d", i); !b.Test(url) {
+			t.Fatalf("Expected %s to be seen\n", url)
+		}
+	}
+	if len(b.Layers) < 2 {
+		t.Errorf("Expected the filter to grow past its first layer. It has: %d\n", len(b.Layers))
+	}
+	if b.Count() < 990 || b.Count() > 1000 {
+		t.Errorf("Expected about 1000 urls. It gave: %d\n", b.Count())
+	}
+}
+
+func TestBloomErrorRate(t *testing.T) {
+	b := NewBloom(1000, 0.01)
+	for i := 0; i < 5000; i++ {
+		b.Add(fmt.Sprintf("http://example.com/%d", i))
+	}
+	wrong := 0
+	for i := 0; i < 10000; i++ {
+		if b.Test(fmt.Sprintf("http://example.org/%d", i)) {
+			wrong++
+		}
+	}
+	if rate := float64(wrong) / 10000; rate > 0.02 {
+		t.Errorf("Expected an error rate around 1%%. It gave: %v\n", rate)
+	}
+}
@@ -0,0 +1,102 @@
+package seen
+
+import (
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/golang/glog"
+)
+
+//Subject is where processes announce the urls they queued or stored,
+//so every Set learns about them
+const Subject = "seen_url"
+
+//Publisher sends messages to gnatsd, a *nats.Conn is one
+type Publisher interface {
+	Publish(subject string, data []byte) error
+}
+
+//Set remembers the urls we already queued, so we don't have to ask the
+//database about every link we find. We trust the filter: about ErrorRate
+//of the new urls are taken as seen and not queued, a lower ErrorRate loses fewer
+type Set struct {
+	mu     sync.RWMutex
+	filter *Bloom
+	pub    Publisher
+}
+
+//New creates a Set on top of filter, announcing the urls we add through pub
+func New(filter *Bloom, pub Publisher) *Set {
+	return &Set{filter: filter, pub: pub}
+}
+
+//Seen tells us if url was queued or stored before
+func (s *Set) Seen(url string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.filter.Test(url)
+}
+
+//Add records url and announces it to the other processes
+func (s *Set) Add(url string) {
+	s.learn(url)
+	if err := Announce(s.pub, url); err != nil {
+		log.Errorf("Failed to announce %s, got: %v\n", url, err)
+	}
+}
+
+//Receive records a url another process announced on Subject
+func (s *Set) Receive(data []byte) {
+	s.learn(string(data))
+}
+
+func (s *Set) learn(url string) {
+	s.mu.Lock()
+	s.filter.Add(url)
+	s.mu.Unlock()
+}
+
+//Save writes the filter to path, replacing it in one go so a crash
+//never leaves half a file behind
+func (s *Set) Save(path string) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	err = gob.NewEncoder(tmp).Encode(s.filter)
+	s.mu.RUnlock()
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+//Announce tells every Set that url was queued or stored
+func Announce(pub Publisher, url string) error {
+	return pub.Publish(Subject, []byte(url))
+}
+
+//Load reads the filter saved at path, or creates an empty one if there is none yet
+func Load(path string, capacity int, errorRate float64) (*Bloom, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return NewBloom(capacity, errorRate), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var b Bloom
+	if err := gob.NewDecoder(f).Decode(&b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
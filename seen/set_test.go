@@ -0,0 +1,53 @@
+package seen
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type publisher struct {
+	sent []string
+}
+
+func (p *publisher) Publish(subject string, data []byte) error {
+	p.sent = append(p.sent, string(data))
+	return nil
+}
+
+func TestSetAnnouncesAndReceives(t *testing.T) {
+	pub := &publisher{}
+	s := New(NewBloom(100, 0.001), pub)
+	s.Add("http://example.com/a")
+	if !s.Seen("http://example.com/a") || len(pub.sent) != 1 {
+		t.Errorf("Added urls should be seen and announced. It sent: %v\n", pub.sent)
+	}
+	s.Receive([]byte("http://example.com/b"))
+	if !s.Seen("http://example.com/b") || len(pub.sent) != 1 {
+		t.Errorf("Received urls should be seen, but not announced again. It sent: %v\n", pub.sent)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "owlcrawler")
+	if err != nil {
+		t.Fatalf("Could not create temp dir, got: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "seen")
+
+	filter, err := Load(path, 100, 0.001)
+	if err != nil || filter.Count() != 0 {
+		t.Fatalf("Expected an empty filter when there is no file. It gave: %v\n", err)
+	}
+	s := New(filter, &publisher{})
+	s.Add("http://example.com/a")
+	if err := s.Save(path); err != nil {
+		t.Fatalf("Save failed with: %v\n", err)
+	}
+	filter, err = Load(path, 100, 0.001)
+	if err != nil || !filter.Test("http://example.com/a") || filter.Test("http://example.com/b") {
+		t.Errorf("The saved filter should know only about /a. It gave: %v\n", err)
+	}
+}
@@ -0,0 +1,128 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+//MaxSize is the most a sitemap can hold once uncompressed, as per sitemaps.org
+const MaxSize = 50 << 20
+
+//URL is one <url> entry of a urlset
+type URL struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	//Priority is between 0.0 and 1.0, nil when the sitemap doesn't say
+	Priority *float64 `xml:"priority"`
+}
+
+//Sitemap is what we read from a sitemap file. An index only has Sitemaps,
+//a urlset only URLs
+type Sitemap struct {
+	URLs     []URL
+	Sitemaps []string
+}
+
+type document struct {
+	XMLName  xml.Name
+	URLs     []URL `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+//Parse reads a urlset or a sitemap index, gzip compressed or not
+func Parse(body []byte) (Sitemap, error) {
+	var ret Sitemap
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return ret, err
+		}
+		body, err = ioutil.ReadAll(io.LimitReader(r, MaxSize))
+		if err != nil {
+			return ret, err
+		}
+	}
+	var doc document
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return ret, err
+	}
+	switch doc.XMLName.Local {
+	case "urlset":
+		for _, u := range doc.URLs {
+			u.Loc = strings.TrimSpace(u.Loc)
+			if u.Loc != "" {
+				ret.URLs = append(ret.URLs, u)
+			}
+		}
+	case "sitemapindex":
+		for _, s := range doc.Sitemaps {
+			if loc := strings.TrimSpace(s.Loc); loc != "" {
+				ret.Sitemaps = append(ret.Sitemaps, loc)
+			}
+		}
+	default:
+		return ret, fmt.Errorf("Not a sitemap, root element is %s", doc.XMLName.Local)
+	}
+	return ret, nil
+}
+
+//lastModFormats are the W3C datetime formats sitemaps use
+var lastModFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+//Modified is when the page last changed, zero if the sitemap doesn't say
+func (u URL) Modified() time.Time {
+	value := strings.TrimSpace(u.LastMod)
+	for _, format := range lastModFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+//ChangeInterval is how often a <changefreq> says the page changes, zero when
+//it's empty or says never
+func ChangeInterval(changeFreq string) time.Duration {
+	switch strings.ToLower(strings.TrimSpace(changeFreq)) {
+	case "always", "hourly":
+		return time.Hour
+	case "daily":
+		return 24 * time.Hour
+	case "weekly":
+		return 7 * 24 * time.Hour
+	case "monthly":
+		return 30 * 24 * time.Hour
+	case "yearly":
+		return 365 * 24 * time.Hour
+	}
+	return 0
+}
+
+//Locations are the sitemaps of the site at siteURL, the ones robots.txt lists
+//or /sitemap.xml when it lists none
+func Locations(siteURL string, fromRobots []string) []string {
+	if len(fromRobots) > 0 {
+		return fromRobots
+	}
+	site, err := url.Parse(siteURL)
+	if err != nil {
+		return nil
+	}
+	return []string{site.Scheme + "://" + site.Host + "/sitemap.xml"}
+}
@@ -0,0 +1,83 @@
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+)
+
+var urlset = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc> http://example.com/ </loc>
+    <lastmod>2015-06-01</lastmod>
+    <changefreq>daily</changefreq>
+    <priority>0.8</priority>
+  </url>
+  <url>
+    <loc>http://example.com/about</loc>
+  </url>
+</urlset>`
+
+var index = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>http://example.com/sitemap1.xml.gz</loc></sitemap>
+  <sitemap><loc>http://example.com/sitemap2.xml</loc><lastmod>2015-06-01</lastmod></sitemap>
+</sitemapindex>`
+
+func TestParseURLSet(t *testing.T) {
+	s, err := Parse([]byte(urlset))
+	if err != nil {
+		t.Fatalf("Parse failed with: %v\n", err)
+	}
+	if len(s.URLs) != 2 || len(s.Sitemaps) != 0 {
+		t.Fatalf("Expected two urls. It gave: %+v\n", s)
+	}
+	home := s.URLs[0]
+	if home.Loc != "http://example.com/" || home.Priority == nil || *home.Priority != 0.8 {
+		t.Errorf("Wrong first url. It gave: %+v\n", home)
+	}
+	if !home.Modified().Equal(time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)) || ChangeInterval(home.ChangeFreq) != 24*time.Hour {
+		t.Errorf("Wrong hints. It gave: %v, %v\n", home.Modified(), ChangeInterval(home.ChangeFreq))
+	}
+	about := s.URLs[1]
+	if about.Priority != nil || !about.Modified().IsZero() || ChangeInterval(about.ChangeFreq) != 0 {
+		t.Errorf("Missing hints should stay empty. It gave: %+v\n", about)
+	}
+}
+
+func TestParseCompressedIndex(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(index))
+	w.Close()
+	s, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse failed with: %v\n", err)
+	}
+	if len(s.Sitemaps) != 2 || s.Sitemaps[0] != "http://example.com/sitemap1.xml.gz" {
+		t.Errorf("Expected two sitemaps. It gave: %+v\n", s)
+	}
+	if _, err := Parse([]byte("<html><body></body></html>")); err == nil {
+		t.Errorf("Html pages are not sitemaps\n")
+	}
+}
+
+func TestModifiedFormats(t *testing.T) {
+	for _, value := range []string{"2015-06-01T10:30:00+02:00", "2015-06-01T08:30Z", "2015-06-01T08:30:00.000Z"} {
+		if got := (URL{LastMod: value}).Modified(); !got.Equal(time.Date(2015, 6, 1, 8, 30, 0, 0, time.UTC)) {
+			t.Errorf("Wrong time for %s. It gave: %v\n", value, got)
+		}
+	}
+}
+
+func TestLocations(t *testing.T) {
+	if l := Locations("http://example.com/blog/", nil); len(l) != 1 || l[0] != "http://example.com/sitemap.xml" {
+		t.Errorf("Expected the default sitemap. It gave: %v\n", l)
+	}
+	robots := []string{"http://example.com/news.xml"}
+	if l := Locations("http://example.com/", robots); len(l) != 1 || l[0] != robots[0] {
+		t.Errorf("Expected the sitemaps in robots.txt. It gave: %v\n", l)
+	}
+}
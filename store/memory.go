@@ -0,0 +1,410 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/couchdb"
+)
+
+//Memory is a Store that keeps everything in memory, useful for tests and quick local runs.
+//It follows the CouchDB rules for revisions
+type Memory struct {
+	mu   sync.Mutex
+	docs map[string]*memoryDoc
+}
+
+type memoryDoc struct {
+	rev  int
+	data []byte
+}
+
+//NewMemory creates an empty Memory store
+func NewMemory() *Memory {
+	return &Memory{docs: make(map[string]*memoryDoc)}
+}
+
+func (doc *memoryDoc) revision() string {
+	return fmt.Sprintf("%d-memory", doc.rev)
+}
+
+//AddURLData adds the url and data to the store, failing if it's already there
+func (m *Memory) AddURLData(url string, data []byte, mainURL bool) (couchdb.CouchDocCreated, error) {
+	id := couchdb.DocID(url)
+	if mainURL {
+		id = "site-" + id
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.docs[id]; ok {
+		return couchdb.CouchDocCreated{}, errors.New("Already saved.")
+	}
+	doc := &memoryDoc{rev: 1, data: data}
+	m.docs[id] = doc
+	return couchdb.CouchDocCreated{OK: true, ID: id, Rev: doc.revision()}, nil
+}
+
+//SaveExtractedTextAndLinks updates the document, data has to include its latest _rev
+func (m *Memory) SaveExtractedTextAndLinks(id string, data []byte) (couchdb.CouchDocCreated, error) {
+	var rev struct {
+		Rev string `json:"_rev"`
+	}
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return couchdb.CouchDocCreated{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.docs[id]
+	if !ok {
+		doc = &memoryDoc{}
+		m.docs[id] = doc
+	} else if doc.revision() != rev.Rev {
+		return couchdb.CouchDocCreated{}, couchdb.ErrorNoLatestVersion
+	}
+	doc.rev++
+	doc.data = data
+	return couchdb.CouchDocCreated{OK: true, ID: id, Rev: doc.revision()}, nil
+}
+
+//UpdateURLData replaces a page we fetched before, data has to include its latest _rev
+func (m *Memory) UpdateURLData(id string, data []byte) (couchdb.CouchDocCreated, error) {
+	return m.SaveExtractedTextAndLinks(id, data)
+}
+
+//GetURLData does a lookup by doc id
+func (m *Memory) GetURLData(id string) (couchdb.CouchDoc, error) {
+	var result couchdb.CouchDoc
+	if err := m.get(id, &result); err != nil {
+		return couchdb.CouchDoc{ID: id}, err
+	}
+	return result, nil
+}
+
+//GetSite gets the site document for a url submitted through the webapp
+func (m *Memory) GetSite(url string) (couchdb.NewSite, error) {
+	var site couchdb.NewSite
+	err := m.get("site-"+couchdb.DocID(url), &site)
+	return site, err
+}
+
+//ShouldURLBeFetched checks if the given url is not stored yet
+func (m *Memory) ShouldURLBeFetched(url string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.docs[couchdb.DocID(url)]
+	return !ok
+}
+
+//IsItParsed checks if the given doc id already has extracted text
+func (m *Memory) IsItParsed(id string) bool {
+	doc, err := m.GetURLData(id)
+	return err == nil && len(doc.Text.Text) > 0
+}
+
+//IndexStats returns the count of fetched and parsed pages and the submitted sites
+func (m *Memory) IndexStats() *couchdb.StatsIndex {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ret := &couchdb.StatsIndex{}
+	for _, doc := range m.docs {
+		var fields struct {
+			Site      string    `json:"site"`
+			FetchedOn time.Time `json:"fetched_on"`
+			ParsedOn  time.Time `json:"parsed_on"`
+		}
+		json.Unmarshal(doc.data, &fields)
+		if !fields.FetchedOn.IsZero() {
+			ret.Fetched++
+		}
+		if !fields.ParsedOn.IsZero() {
+			ret.Parsed++
+		}
+		if fields.Site != "" {
+			ret.Sites = append(ret.Sites, fields.Site)
+		}
+	}
+	sort.Strings(ret.Sites)
+	return ret
+}
+
+//DuePages lists up to limit pages of site that should have been revisited before the given time,
+//the ones that are late the longest first
+func (m *Memory) DuePages(site string, before time.Time, limit int) ([]couchdb.DuePage, error) {
+	m.mu.Lock()
+	var ret []couchdb.DuePage
+	for id, doc := range m.docs {
+		var page couchdb.CouchDoc
+		if err := json.Unmarshal(doc.data, &page); err != nil || page.FetchedOn.IsZero() || page.URL == "" || page.Site != site {
+			continue
+		}
+		next := page.FetchedOn
+		if page.Revisit != nil && !page.Revisit.NextVisit.IsZero() {
+			next = page.Revisit.NextVisit
+		}
+		if next.After(before) {
+			continue
+		}
+		ret = append(ret, couchdb.DuePage{ID: id, URL: page.URL, Site: page.Site, Depth: page.Depth, NextVisit: next})
+	}
+	m.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool { return ret[i].NextVisit.Before(ret[j].NextVisit) })
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+//PageSites lists the sites we fetched pages of, pages without a site are listed under ""
+func (m *Memory) PageSites() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	found := make(map[string]bool)
+	for _, doc := range m.docs {
+		var page couchdb.CouchDoc
+		if err := json.Unmarshal(doc.data, &page); err != nil || page.FetchedOn.IsZero() || page.URL == "" {
+			continue
+		}
+		found[page.Site] = true
+	}
+	var ret []string
+	for site := range found {
+		ret = append(ret, site)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+//AddDeadLetter records a url we gave up fetching, replacing an older record of it
+func (m *Memory) AddDeadLetter(letter couchdb.DeadLetter) error {
+	letter.ID = couchdb.DeadLetterID(letter.URL)
+	letter.Rev = ""
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.docs[letter.ID]
+	if !ok {
+		doc = &memoryDoc{}
+		m.docs[letter.ID] = doc
+	}
+	doc.rev++
+	doc.data = data
+	return nil
+}
+
+//DeadLetters lists up to limit urls we gave up fetching, the latest first
+func (m *Memory) DeadLetters(limit int) ([]couchdb.DeadLetter, error) {
+	var ret []couchdb.DeadLetter
+	for _, id := range m.ids("dead-") {
+		if letter, err := m.GetDeadLetter(id); err == nil {
+			ret = append(ret, letter)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].FailedOn.After(ret[j].FailedOn) })
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+//GetDeadLetter does a lookup by dead letter id
+func (m *Memory) GetDeadLetter(id string) (couchdb.DeadLetter, error) {
+	var letter couchdb.DeadLetter
+	err := m.get(id, &letter)
+	return letter, err
+}
+
+//RemoveDeadLetter deletes the dead letter, once it's requeued
+func (m *Memory) RemoveDeadLetter(id string) error {
+	return m.remove(id)
+}
+
+//Enqueue adds entry to the frontier, telling us if it wasn't there already
+func (m *Memory) Enqueue(entry couchdb.FrontierEntry) (bool, error) {
+	entry.ID = couchdb.FrontierID(entry.URL)
+	entry.Rev = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return false, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.docs[entry.ID]; ok {
+		return false, nil
+	}
+	m.docs[entry.ID] = &memoryDoc{rev: 1, data: data}
+	return true, nil
+}
+
+//UpdateFrontier saves entry, which has to have its latest _rev
+func (m *Memory) UpdateFrontier(entry couchdb.FrontierEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = m.SaveExtractedTextAndLinks(entry.ID, data)
+	return err
+}
+
+//GetFrontier does a lookup by frontier entry id
+func (m *Memory) GetFrontier(id string) (couchdb.FrontierEntry, error) {
+	var entry couchdb.FrontierEntry
+	err := m.get(id, &entry)
+	return entry, err
+}
+
+//RemoveFrontier takes the entry out of the frontier, once a fetcher is done with it
+func (m *Memory) RemoveFrontier(id string) error {
+	return m.remove(id)
+}
+
+//DueFrontier lists up to limit entries that should be back in the queue before the given time
+func (m *Memory) DueFrontier(before time.Time, limit int) ([]couchdb.FrontierEntry, error) {
+	var ret []couchdb.FrontierEntry
+	for _, entry := range m.frontier() {
+		if !entry.VisibleAt.After(before) {
+			ret = append(ret, entry)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].VisibleAt.Before(ret[j].VisibleAt) })
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+//FrontierDepth counts the urls waiting in the frontier, per site
+func (m *Memory) FrontierDepth() (map[string]int, error) {
+	ret := make(map[string]int)
+	for _, entry := range m.frontier() {
+		ret[entry.Site]++
+	}
+	return ret, nil
+}
+
+//SaveFeed records feed, replacing what we had about it
+func (m *Memory) SaveFeed(feed couchdb.Feed) error {
+	feed.ID = couchdb.FeedID(feed.URL)
+	feed.Rev = ""
+	data, err := json.Marshal(feed)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.docs[feed.ID]
+	if !ok {
+		doc = &memoryDoc{}
+		m.docs[feed.ID] = doc
+	}
+	doc.rev++
+	doc.data = data
+	return nil
+}
+
+//GetFeed does a lookup by feed id
+func (m *Memory) GetFeed(id string) (couchdb.Feed, error) {
+	var feed couchdb.Feed
+	err := m.get(id, &feed)
+	return feed, err
+}
+
+//DueFeeds lists up to limit feeds we should have read again before the given time
+func (m *Memory) DueFeeds(before time.Time, limit int) ([]couchdb.Feed, error) {
+	var ret []couchdb.Feed
+	for _, id := range m.ids("feed-") {
+		if feed, err := m.GetFeed(id); err == nil && !feed.NextPoll.After(before) {
+			ret = append(ret, feed)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].NextPoll.Before(ret[j].NextPoll) })
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+//Inlinks lists up to limit links other pages have to url
+func (m *Memory) Inlinks(url string, limit int) ([]couchdb.Inlink, error) {
+	m.mu.Lock()
+	var ret []couchdb.Inlink
+	for _, doc := range m.docs {
+		var page couchdb.CouchDoc
+		if err := json.Unmarshal(doc.data, &page); err != nil || page.URL == "" || page.URL == url {
+			continue
+		}
+		for _, link := range page.Links {
+			if link.URL == url {
+				ret = append(ret, couchdb.Inlink{From: page.URL, Text: link.Text, NoFollow: link.NoFollow})
+			}
+		}
+	}
+	m.mu.Unlock()
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].From < ret[j].From })
+	if len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return ret, nil
+}
+
+func (m *Memory) frontier() []couchdb.FrontierEntry {
+	var ret []couchdb.FrontierEntry
+	for _, id := range m.ids("queue-") {
+		if entry, err := m.GetFrontier(id); err == nil {
+			ret = append(ret, entry)
+		}
+	}
+	return ret
+}
+
+//ids lists the documents whose id starts with prefix
+func (m *Memory) ids(prefix string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for id := range m.docs {
+		if strings.HasPrefix(id, prefix) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+//remove deletes the document with the given id
+func (m *Memory) remove(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.docs[id]; !ok {
+		return couchdb.Error404
+	}
+	delete(m.docs, id)
+	return nil
+}
+
+//get reads the document with the given id into v, adding its id and revision
+func (m *Memory) get(id string, v interface{}) error {
+	m.mu.Lock()
+	doc, ok := m.docs[id]
+	var data []byte
+	var rev string
+	if ok {
+		data = doc.data
+		rev = doc.revision()
+	}
+	m.mu.Unlock()
+	if !ok {
+		return couchdb.Error404
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	meta, _ := json.Marshal(map[string]string{"_id": id, "_rev": rev})
+	return json.Unmarshal(meta, v)
+}
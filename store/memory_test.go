@@ -0,0 +1,170 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/parse"
+	"github.com/fmpwizard/owlcrawler/recrawl"
+)
+
+func TestMemoryAddAndGet(t *testing.T) {
+	var s Store = NewMemory()
+	if !s.ShouldURLBeFetched("http://example.com/a") {
+		t.Errorf("Empty store should fetch everything\n")
+	}
+	ret, err := s.AddURLData("http://example.com/a", []byte(`{"url":"http://example.com/a","html":"<p>hi</p>"}`), false)
+	if err != nil {
+		t.Fatalf("AddURLData failed with: %v\n", err)
+	}
+	if ret.ID != couchdb.DocID("http://example.com/a") {
+		t.Errorf("Unexpected doc id. It gave: %s\n", ret.ID)
+	}
+	if s.ShouldURLBeFetched("http://Example.com:80/a#top") {
+		t.Errorf("Stored url should not be fetched again\n")
+	}
+	if _, err := s.AddURLData("http://example.com/a", []byte(`{}`), false); err == nil {
+		t.Errorf("Adding the same url twice should fail\n")
+	}
+	doc, err := s.GetURLData(ret.ID)
+	if err != nil || doc.HTML != "<p>hi</p>" || doc.Rev != ret.Rev || doc.ID != ret.ID {
+		t.Errorf("GetURLData didn't give us the stored doc. It gave: %+v, %v\n", doc, err)
+	}
+	if _, err := s.GetURLData("missing"); err != couchdb.Error404 {
+		t.Errorf("Expected Error404. It gave: %v\n", err)
+	}
+}
+
+func TestMemoryRevisions(t *testing.T) {
+	s := NewMemory()
+	ret, _ := s.AddURLData("http://example.com/a", []byte(`{"url":"http://example.com/a"}`), false)
+	doc, _ := s.GetURLData(ret.ID)
+	doc.Text.Text = []string{"hello"}
+	data, _ := json.Marshal(doc)
+	if _, err := s.SaveExtractedTextAndLinks(doc.ID, data); err != nil {
+		t.Fatalf("SaveExtractedTextAndLinks failed with: %v\n", err)
+	}
+	if _, err := s.SaveExtractedTextAndLinks(doc.ID, data); err != couchdb.ErrorNoLatestVersion {
+		t.Errorf("Saving an old revision should fail. It gave: %v\n", err)
+	}
+	if !s.IsItParsed(doc.ID) {
+		t.Errorf("Doc with text should be parsed\n")
+	}
+}
+
+func TestMemorySites(t *testing.T) {
+	s := NewMemory()
+	data, _ := json.Marshal(couchdb.NewSite{Site: "http://example.com", CrawlDelay: 3})
+	s.AddURLData("http://example.com", data, true)
+	site, err := FindSite(s, "", "http://example.com/docs/page.html")
+	if err != nil || site.CrawlDelay != 3 {
+		t.Errorf("FindSite didn't find the site. It gave: %+v, %v\n", site, err)
+	}
+	stats := s.IndexStats()
+	if len(stats.Sites) != 1 || stats.Sites[0] != "http://example.com" {
+		t.Errorf("IndexStats didn't list the site. It gave: %+v\n", stats)
+	}
+}
+
+func TestMemoryDuePages(t *testing.T) {
+	s := NewMemory()
+	now := time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := &recrawl.History{NextVisit: now.Add(time.Hour)}
+	earlier := &recrawl.History{NextVisit: now.Add(-time.Hour)}
+	pages := []couchdb.CouchDoc{
+		{URL: "http://example.com/later", FetchedOn: now, Revisit: later},
+		{URL: "http://example.com/earlier", FetchedOn: now, Revisit: earlier},
+		{URL: "http://example.com/old", FetchedOn: now.Add(-2 * time.Hour)},
+		{URL: "http://example.org/older", Site: "http://example.org", FetchedOn: now.Add(-3 * time.Hour)},
+	}
+	for _, page := range pages {
+		data, _ := json.Marshal(page)
+		s.AddURLData(page.URL, data, false)
+	}
+	due, err := s.DuePages("", now, 10)
+	if err != nil || len(due) != 2 || due[0].URL != "http://example.com/old" || due[1].URL != "http://example.com/earlier" {
+		t.Errorf("Wrong due pages. It gave: %+v, %v\n", due, err)
+	}
+	if due, _ := s.DuePages("", now, 1); len(due) != 1 {
+		t.Errorf("DuePages should honor the limit. It gave: %+v\n", due)
+	}
+	if due, _ := s.DuePages("http://example.org", now, 10); len(due) != 1 || due[0].URL != "http://example.org/older" {
+		t.Errorf("DuePages should only list the pages of the site. It gave: %+v\n", due)
+	}
+	sites, err := s.PageSites()
+	if err != nil || len(sites) != 2 || sites[0] != "" || sites[1] != "http://example.org" {
+		t.Errorf("Wrong page sites. It gave: %+v, %v\n", sites, err)
+	}
+}
+
+func TestAnchorTextsOfLaterInlinks(t *testing.T) {
+	s := NewMemory()
+	s.AddURLData("http://example.com/a", []byte(`{"url":"http://example.com/a","fetched_on":"2015-06-01T00:00:00Z"}`), false)
+	linker := couchdb.CouchDoc{URL: "http://example.com/b", FetchedOn: time.Now().UTC(), Links: []parse.Link{
+		{URL: "http://example.com/a", Text: "Owl guide"},
+		{URL: "http://example.com/a", Text: "owl GUIDE"},
+		{URL: "http://example.com/a", Text: "Sponsored", NoFollow: true},
+	}}
+	data, _ := json.Marshal(linker)
+	s.AddURLData(linker.URL, data, false)
+	texts, err := AnchorTexts(s, "http://example.com/a")
+	if err != nil || len(texts) != 1 || texts[0] != "Owl guide" {
+		t.Errorf("Expected the anchor text of the page stored later. It gave: %+v, %v\n", texts, err)
+	}
+}
+
+func TestMemoryDeadLetters(t *testing.T) {
+	s := NewMemory()
+	now := time.Now()
+	s.AddDeadLetter(couchdb.DeadLetter{URL: "http://example.com/a", Reason: "timeout", Attempts: 5, FailedOn: now.Add(-time.Hour)})
+	s.AddDeadLetter(couchdb.DeadLetter{URL: "http://example.com/b", Reason: "Got status 503", Attempts: 5, FailedOn: now})
+	s.AddDeadLetter(couchdb.DeadLetter{URL: "http://example.com/a", Reason: "timeout", Attempts: 5, FailedOn: now.Add(-time.Minute)})
+	letters, err := s.DeadLetters(10)
+	if err != nil || len(letters) != 2 || letters[0].URL != "http://example.com/b" {
+		t.Fatalf("Wrong dead letters. It gave: %+v, %v\n", letters, err)
+	}
+	if !s.ShouldURLBeFetched("http://example.com/a") {
+		t.Errorf("Dead letters should not count as fetched pages\n")
+	}
+	if err := s.RemoveDeadLetter(letters[0].ID); err != nil {
+		t.Errorf("RemoveDeadLetter failed with: %v\n", err)
+	}
+	if _, err := s.GetDeadLetter(letters[0].ID); err != couchdb.Error404 {
+		t.Errorf("Expected Error404. It gave: %v\n", err)
+	}
+}
+
+func TestMemoryFeeds(t *testing.T) {
+	s := NewMemory()
+	now := time.Now()
+	s.SaveFeed(couchdb.Feed{URL: "http://example.com/feed.xml", NextPoll: now.Add(time.Hour)})
+	s.SaveFeed(couchdb.Feed{URL: "http://example.com/atom.xml", NextPoll: now.Add(-time.Minute)})
+	due, err := s.DueFeeds(now, 10)
+	if err != nil || len(due) != 1 || due[0].URL != "http://example.com/atom.xml" {
+		t.Fatalf("Expected only the atom feed to be due. It gave: %+v, %v\n", due, err)
+	}
+	s.SaveFeed(couchdb.Feed{URL: "http://example.com/feed.xml", NextPoll: now.Add(-time.Hour), Entries: 3})
+	due, _ = s.DueFeeds(now, 10)
+	if len(due) != 2 || due[0].URL != "http://example.com/feed.xml" || due[0].Entries != 3 {
+		t.Errorf("Saving a feed again should replace it. It gave: %+v\n", due)
+	}
+	if _, err := s.GetFeed(couchdb.FeedID("http://example.com/feed.xml")); err != nil {
+		t.Errorf("GetFeed failed with: %v\n", err)
+	}
+}
+
+func TestMemoryInlinks(t *testing.T) {
+	s := NewMemory()
+	s.AddURLData("http://example.com/b", []byte(`{"url":"http://example.com/b","links":[{"url":"http://example.com/a","text":"Owls"}]}`), false)
+	s.AddURLData("http://example.com/c", []byte(`{"url":"http://example.com/c","links":["http://example.com/a"]}`), false)
+	s.AddURLData("http://example.com/a", []byte(`{"url":"http://example.com/a","links":[{"url":"http://example.com/a","text":"Self"}]}`), false)
+	inlinks, err := s.Inlinks("http://example.com/a", 10)
+	if err != nil || len(inlinks) != 2 {
+		t.Fatalf("Expected the links of b and c. It gave: %+v, %v\n", inlinks, err)
+	}
+	if inlinks[0].From != "http://example.com/b" || inlinks[0].Text != "Owls" || inlinks[1].From != "http://example.com/c" {
+		t.Errorf("Wrong inlinks. It gave: %+v\n", inlinks)
+	}
+}
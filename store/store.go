@@ -0,0 +1,143 @@
+package store
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fmpwizard/owlcrawler/cloudant"
+	"github.com/fmpwizard/owlcrawler/config"
+	"github.com/fmpwizard/owlcrawler/couchdb"
+	"github.com/fmpwizard/owlcrawler/scope"
+)
+
+//maxAnchors is how many links to a page we read its anchor text from
+const maxAnchors = 100
+
+//Store is where we keep fetched pages, their extracted data and the submitted sites
+type Store interface {
+	//AddURLData adds the url and data to the database. data is json encoded.
+	//mainURL is true for sites submitted through the webapp
+	AddURLData(url string, data []byte, mainURL bool) (couchdb.CouchDocCreated, error)
+	//SaveExtractedTextAndLinks updates an existing document, data has to include its latest revision
+	SaveExtractedTextAndLinks(id string, data []byte) (couchdb.CouchDocCreated, error)
+	//UpdateURLData replaces a page we fetched before, data has to include its latest revision
+	UpdateURLData(id string, data []byte) (couchdb.CouchDocCreated, error)
+	//GetURLData does a lookup by doc id
+	GetURLData(id string) (couchdb.CouchDoc, error)
+	//GetSite gets the site document for a url submitted through the webapp
+	GetSite(url string) (couchdb.NewSite, error)
+	//ShouldURLBeFetched checks if the given url is not stored yet
+	ShouldURLBeFetched(url string) bool
+	//IsItParsed checks if the given doc id already has extracted text
+	IsItParsed(id string) bool
+	//IndexStats returns stats related to the index
+	IndexStats() *couchdb.StatsIndex
+	//DuePages lists up to limit pages of site that should have been revisited before the given time
+	DuePages(site string, before time.Time, limit int) ([]couchdb.DuePage, error)
+	//PageSites lists the sites we fetched pages of, pages without a site are listed under ""
+	PageSites() ([]string, error)
+	//AddDeadLetter records a url we gave up fetching, replacing an older record of it
+	AddDeadLetter(letter couchdb.DeadLetter) error
+	//DeadLetters lists up to limit urls we gave up fetching, the latest first
+	DeadLetters(limit int) ([]couchdb.DeadLetter, error)
+	//GetDeadLetter does a lookup by dead letter id
+	GetDeadLetter(id string) (couchdb.DeadLetter, error)
+	//RemoveDeadLetter deletes the dead letter, once it's requeued
+	RemoveDeadLetter(id string) error
+	//Enqueue adds entry to the frontier, telling us if it wasn't there already
+	Enqueue(entry couchdb.FrontierEntry) (bool, error)
+	//UpdateFrontier saves entry, which has to have its latest _rev
+	UpdateFrontier(entry couchdb.FrontierEntry) error
+	//GetFrontier does a lookup by frontier entry id
+	GetFrontier(id string) (couchdb.FrontierEntry, error)
+	//RemoveFrontier takes the entry out of the frontier, once a fetcher is done with it
+	RemoveFrontier(id string) error
+	//DueFrontier lists up to limit entries that should be back in the queue before the given time
+	DueFrontier(before time.Time, limit int) ([]couchdb.FrontierEntry, error)
+	//FrontierDepth counts the urls waiting in the frontier, per site
+	FrontierDepth() (map[string]int, error)
+	//SaveFeed records feed, replacing what we had about it
+	SaveFeed(feed couchdb.Feed) error
+	//GetFeed does a lookup by feed id
+	GetFeed(id string) (couchdb.Feed, error)
+	//DueFeeds lists up to limit feeds we should have read again before the given time
+	DueFeeds(before time.Time, limit int) ([]couchdb.Feed, error)
+	//Inlinks lists up to limit links other pages have to url
+	Inlinks(url string, limit int) ([]couchdb.Inlink, error)
+}
+
+//Open creates the Store selected in the config: couchdb, cloudant or memory
+func Open(cfg *config.Config) (Store, error) {
+	switch cfg.Store {
+	case "couchdb":
+		db, err := couchdb.New(cfg.CouchDB)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case "cloudant":
+		db, err := cloudant.New(cfg.Cloudant)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case "memory":
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("Unknown store %s", cfg.Store)
+}
+
+//SiteForURL finds the site document a page belongs to, using the page's scheme and host
+func SiteForURL(s Store, pageURL string) (couchdb.NewSite, error) {
+	link, err := url.Parse(pageURL)
+	if err != nil {
+		return couchdb.NewSite{}, err
+	}
+	return s.GetSite(link.Scheme + "://" + link.Host)
+}
+
+//FindSite gets the site document a page belongs to, using the seed site url when we know it
+func FindSite(s Store, seed, pageURL string) (couchdb.NewSite, error) {
+	if seed != "" {
+		return s.GetSite(seed)
+	}
+	return SiteForURL(s, pageURL)
+}
+
+//SiteScope compiles the scope rules of the site a page belongs to. Pages of sites we
+//don't know get the default rules, and so do sites whose rules don't compile, along with the error
+func SiteScope(s Store, seed, pageURL string) (*scope.Scope, error) {
+	site, err := FindSite(s, seed, pageURL)
+	if err != nil {
+		site = couchdb.NewSite{Site: pageURL}
+	}
+	siteScope, err := site.CompileScope()
+	if err != nil {
+		siteScope, _ = scope.DefaultRules.Compile(pageURL)
+		return siteScope, fmt.Errorf("Invalid scope for site %s, got: %v", site.Site, err)
+	}
+	return siteScope, nil
+}
+
+//AnchorTexts collects the text of the links other pages have to url, leaving out
+//nofollow links and repeated texts. Pages call it when they are extracted or found
+//unchanged, so links stored after them are picked up on the next visit
+func AnchorTexts(s Store, url string) ([]string, error) {
+	inlinks, err := s.Inlinks(url, maxAnchors)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	seenTexts := make(map[string]bool)
+	for _, link := range inlinks {
+		text := strings.ToLower(link.Text)
+		if link.NoFollow || text == "" || seenTexts[text] {
+			continue
+		}
+		seenTexts[text] = true
+		ret = append(ret, link.Text)
+	}
+	return ret, nil
+}
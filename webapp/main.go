@@ -5,18 +5,23 @@ import (
 	_ "expvar"
 	"flag"
 	"fmt"
+	"github.com/fmpwizard/owlcrawler/config"
 	"github.com/fmpwizard/owlcrawler/couchdb"
 	"github.com/fmpwizard/owlcrawler/elasticsearch"
+	"github.com/fmpwizard/owlcrawler/frontier"
+	"github.com/fmpwizard/owlcrawler/parse"
+	"github.com/fmpwizard/owlcrawler/queue"
+	"github.com/fmpwizard/owlcrawler/scope"
+	"github.com/fmpwizard/owlcrawler/store"
 	log "github.com/golang/glog"
 	"github.com/nats-io/nats"
 	"html/template"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"os/user"
 	"path"
-	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -33,19 +38,35 @@ type TemplateInfo struct {
 	Term    string
 }
 
-var gnatsdCredentials gnatsdCred
-
-type gnatsdCred struct {
-	URL string
-}
+var configLoader = config.Flags(flag.CommandLine)
+var cfg *config.Config
+var db store.Store
 
 //IndexStats includes information from our search engine index
 type IndexStats struct {
 	FetchedPages int
 	ParsedPages  int
 	Sites        []string
+	//Queued are the urls waiting to be fetched, per site
+	Queued     []QueueDepth
+	QueuedURLs int
 }
 
+//QueueDepth is how many urls of a site are waiting to be fetched
+type QueueDepth struct {
+	Site string
+	URLs int
+}
+
+//DeadLetters lists the urls we gave up fetching
+type DeadLetters struct {
+	Letters []couchdb.DeadLetter
+	Message string
+}
+
+//deadLettersShown is how many failed urls we list
+const deadLettersShown = 200
+
 var rootDir string
 
 func init() {
@@ -56,27 +77,27 @@ func init() {
 		currentDir,
 		"specifies the root dir where html and other files will be relative to",
 	)
-	if u, err := user.Current(); err == nil {
-		path := filepath.Join(u.HomeDir, ".gnatsd.json")
-		content, err := ioutil.ReadFile(path)
-		if err != nil {
-			log.Fatalf("Error reading gnatds user file, got: %v\n", err)
-		}
-
-		err = json.Unmarshal(content, &gnatsdCredentials)
-		if err != nil {
-			log.Fatalf("Invalid gnatsd credentials file, got: %v\n", err)
-		}
-	}
 }
 
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	flag.Parse()
+	var err error
+	cfg, err = configLoader.Load()
+	if err != nil {
+		log.Fatalf("Invalid configuration, got: %v\n", err)
+	}
+	db, err = store.Open(cfg)
+	if err != nil {
+		log.Fatalf("Could not open %s store, got: %v\n", cfg.Store, err)
+	}
 	http.HandleFunc("/", indexHandler)
 	http.HandleFunc("/index", search)
 	http.HandleFunc("/add-site", addSiteToIndex)
 	http.HandleFunc("/index-status", indexStatus)
+	http.HandleFunc("/dead-letters", deadLetters)
+	http.HandleFunc("/requeue", requeue)
+	http.HandleFunc("/crawl-now", crawlNow)
 	http.Handle("/bower_components/", http.StripPrefix("/bower_components/", http.FileServer(http.Dir("bower_components"))))
 	http.Handle("/styles/", http.StripPrefix("/styles/", http.FileServer(http.Dir(".tmp/styles"))))
 	http.Handle("/scripts/", http.StripPrefix("/scripts/", http.FileServer(http.Dir("app/scripts"))))
@@ -117,10 +138,14 @@ func search(rw http.ResponseWriter, req *http.Request) {
 		for _, highlight := range row.Highlight.Text {
 			txt = txt + " ... " + highlight
 		}
+		snippet := sanitizeHTML(txt)
+		if txt == "" {
+			snippet = template.HTML(template.HTMLEscapeString(description(row.Source.Text.Meta)))
+		}
 		foundSet = append(foundSet, &message{
 			ID:    row.Source.ID,
 			URL:   row.Source.URL,
-			Text:  sanitizeHTML(txt),
+			Text:  snippet,
 			Title: row.Source.Text.Title,
 		})
 	}
@@ -134,6 +159,14 @@ func search(rw http.ResponseWriter, req *http.Request) {
 
 }
 
+//description is what we show for results that didn't match on their text
+func description(meta parse.Meta) string {
+	if meta.Description != "" {
+		return meta.Description
+	}
+	return meta.OpenGraph["description"]
+}
+
 func sanitizeHTML(s string) template.HTML {
 	return template.HTML(
 		strings.Replace(
@@ -145,7 +178,16 @@ func addSiteToIndex(rw http.ResponseWriter, req *http.Request) {
 	t := htmlTemplate("add-site.html", "app/add-site.html")
 	rw.Header().Add("Content-Type", "text/html; charset=UTF-8")
 	if url != "" {
-		saveSubmittedURL(url, rw, t)
+		crawlDelay, _ := strconv.Atoi(req.FormValue("crawl-delay"))
+		site := &couchdb.NewSite{Site: url, CrawlDelay: crawlDelay, Scope: scopeFromForm(req)}
+		if _, err := site.CompileScope(); err != nil {
+			err := t.ExecuteTemplate(rw, "add-site.html", err.Error())
+			if err != nil {
+				log.Errorf("Error executing template, got: %s\n", err)
+			}
+			return
+		}
+		saveSubmittedURL(site, rw, t)
 	} else {
 		err := t.ExecuteTemplate(rw, "add-site.html", "")
 		if err != nil {
@@ -154,14 +196,41 @@ func addSiteToIndex(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func saveSubmittedURL(url string, rw http.ResponseWriter, t *template.Template) {
-	data := &couchdb.NewSite{Site: url}
-	payload, err := json.Marshal(data)
+//scopeFromForm reads the crawl scope rules submitted with a new site
+func scopeFromForm(req *http.Request) *scope.Rules {
+	rules := &scope.Rules{
+		SameHost:   req.FormValue("same-host") != "",
+		SameDomain: req.FormValue("same-domain") != "",
+	}
+	for _, host := range strings.Split(req.FormValue("allowed-hosts"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			rules.AllowedHosts = append(rules.AllowedHosts, host)
+		}
+	}
+	if include := req.FormValue("include"); include != "" {
+		rules.Include = []string{include}
+	}
+	if exclude := req.FormValue("exclude"); exclude != "" {
+		rules.Exclude = []string{exclude}
+	}
+	if !rules.LimitsHosts() {
+		//Otherwise unchecking same host would crawl the whole web
+		rules.SameHost = scope.DefaultRules.SameHost
+		rules.SameDomain = scope.DefaultRules.SameDomain
+		rules.AllowedHosts = scope.DefaultRules.AllowedHosts
+	}
+	rules.MaxDepth, _ = strconv.Atoi(req.FormValue("max-depth"))
+	return rules
+}
+
+func saveSubmittedURL(site *couchdb.NewSite, rw http.ResponseWriter, t *template.Template) {
+	url := site.Site
+	payload, err := json.Marshal(site)
 	if err != nil {
 		log.Errorf("Error generating json to save in database, got: %v\n", err)
 	}
 
-	_, err = couchdb.AddURLData(url, payload, true)
+	_, err = db.AddURLData(url, payload, true)
 	if err != nil {
 		log.Errorf("Error adding site to db, got: %s\n", err)
 		err := t.ExecuteTemplate(rw, "add-site.html", err.Error())
@@ -171,7 +240,7 @@ func saveSubmittedURL(url string, rw http.ResponseWriter, t *template.Template)
 		return
 	}
 
-	nc, err := nats.Connect(gnatsdCredentials.URL)
+	nc, err := nats.Connect(cfg.Gnatsd.URL)
 	if err != nil {
 		log.Errorf("Could not connect to gnatsd, got: %s\n", err)
 		err := t.ExecuteTemplate(rw, "add-site.html", err.Error())
@@ -180,7 +249,8 @@ func saveSubmittedURL(url string, rw http.ResponseWriter, t *template.Template)
 		}
 		return
 	}
-	pushError := nc.Publish("fetch_url", []byte(url))
+	defer nc.Close()
+	pushError := newFrontier(nc).Push(queue.Message{URL: url, Site: url, Priority: queue.PriorityHigh})
 	if pushError != nil {
 		log.Errorf("Error searching, got %v", err)
 		err := t.ExecuteTemplate(rw, "add-site.html", pushError.Error())
@@ -195,18 +265,115 @@ func saveSubmittedURL(url string, rw http.ResponseWriter, t *template.Template)
 	}
 }
 
+//crawlNow sends a url ahead of everything else in the fetch queue,
+//fetching it again even if we already have it
+func crawlNow(rw http.ResponseWriter, req *http.Request) {
+	t := htmlTemplate("add-site.html", "app/add-site.html")
+	rw.Header().Add("Content-Type", "text/html; charset=UTF-8")
+	message := "Crawling " + req.FormValue("url")
+	url, err := parse.NormalizeURL(req.FormValue("url"))
+	if req.Method != "POST" || err != nil || url == "" {
+		message = "Invalid url"
+	} else if err := pushNow(url); err != nil {
+		log.Errorf("Error queueing %s, got: %v\n", url, err)
+		message = err.Error()
+	}
+	if err := t.ExecuteTemplate(rw, "add-site.html", message); err != nil {
+		log.Errorf("Error executing template, got: %s\n", err)
+	}
+}
+
+func pushNow(url string) error {
+	msg := queue.Message{URL: url, Priority: queue.PriorityNow, Recrawl: true}
+	if site, err := store.SiteForURL(db, url); err == nil {
+		msg.Site = site.Site
+	}
+	nc, err := nats.Connect(cfg.Gnatsd.URL)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	return newFrontier(nc).Push(msg)
+}
+
+//newFrontier is how we send urls to the fetchers
+func newFrontier(nc *nats.Conn) *frontier.Frontier {
+	return frontier.New(db, nc, cfg.Frontier.AckTimeout.Duration, cfg.Frontier.MaxDeliveries)
+}
+
 func indexStatus(rw http.ResponseWriter, req *http.Request) {
 	t := htmlTemplate("index-status.html", "app/index-status.html")
 	rw.Header().Add("Content-Type", "text/html; charset=UTF-8")
-	stats := couchdb.IndexStats()
+	stats := db.IndexStats()
 	info := &IndexStats{
 		FetchedPages: stats.Fetched,
 		ParsedPages:  stats.Parsed,
 		Sites:        stats.Sites,
 	}
+	depth, err := db.FrontierDepth()
+	if err != nil {
+		log.Errorf("Error getting the queue depth, got: %v\n", err)
+	}
+	for site, urls := range depth {
+		if site == "" {
+			site = "(no site)"
+		}
+		info.Queued = append(info.Queued, QueueDepth{Site: site, URLs: urls})
+		info.QueuedURLs += urls
+	}
+	sort.Slice(info.Queued, func(i, j int) bool { return info.Queued[i].URLs > info.Queued[j].URLs })
+
+	err = t.ExecuteTemplate(rw, "index-status.html", info)
+	if err != nil {
+		log.Errorf("Error executing template, got: %s\n", err)
+	}
+}
+
+func deadLetters(rw http.ResponseWriter, req *http.Request) {
+	showDeadLetters(rw, "")
+}
 
-	err := t.ExecuteTemplate(rw, "index-status.html", info)
+func showDeadLetters(rw http.ResponseWriter, message string) {
+	t := htmlTemplate("dead-letters.html", "app/dead-letters.html")
+	rw.Header().Add("Content-Type", "text/html; charset=UTF-8")
+	letters, err := db.DeadLetters(deadLettersShown)
+	if err != nil {
+		log.Errorf("Error getting dead letters, got: %v\n", err)
+		message = err.Error()
+	}
+	err = t.ExecuteTemplate(rw, "dead-letters.html", &DeadLetters{Letters: letters, Message: message})
 	if err != nil {
 		log.Errorf("Error executing template, got: %s\n", err)
 	}
 }
+
+//requeue sends a url we gave up fetching back to the fetch queue, with its attempts reset
+func requeue(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Redirect(rw, req, "/dead-letters", http.StatusSeeOther)
+		return
+	}
+	letter, err := db.GetDeadLetter(req.FormValue("id"))
+	if err != nil {
+		showDeadLetters(rw, "Could not find that url, got: "+err.Error())
+		return
+	}
+	msg := letter.Message
+	msg.Retries = 0
+	nc, err := nats.Connect(cfg.Gnatsd.URL)
+	if err != nil {
+		log.Errorf("Could not connect to gnatsd, got: %s\n", err)
+		showDeadLetters(rw, err.Error())
+		return
+	}
+	defer nc.Close()
+	if err := newFrontier(nc).Push(msg); err != nil {
+		log.Errorf("Error requeueing %s, got: %v\n", letter.URL, err)
+		showDeadLetters(rw, err.Error())
+		return
+	}
+	if err := db.RemoveDeadLetter(letter.ID); err != nil {
+		log.Errorf("Error removing dead letter %s, got: %v\n", letter.ID, err)
+	}
+	showDeadLetters(rw, letter.URL+" requeued")
+}